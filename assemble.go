@@ -0,0 +1,226 @@
+package egmanifest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUnsafePath = errors.New("file name points outside of the install directory")
+)
+
+// partRef ties a chunk part to the file it is written to.
+type partRef struct {
+	file       *File
+	part       *ChunkPart
+	path       string
+	fileOffset int64
+}
+
+// chunkRefs groups the chunk parts of files by their chunk, keeping the
+// chunks in the order they're first referenced.
+type chunkRefs struct {
+	order []*Chunk
+	refs  map[uuid.UUID][]partRef
+}
+
+func newChunkRefs() *chunkRefs {
+	return &chunkRefs{refs: map[uuid.UUID][]partRef{}}
+}
+
+func (cr *chunkRefs) add(ref partRef) {
+	if _, ok := cr.refs[ref.part.ParentGUID]; !ok {
+		cr.order = append(cr.order, ref.part.Chunk)
+	}
+	cr.refs[ref.part.ParentGUID] = append(cr.refs[ref.part.ParentGUID], ref)
+}
+
+func collectChunkRefs(files []*File, pathFor func(*File) string) *chunkRefs {
+	cr := newChunkRefs()
+	for _, file := range files {
+		if file.SymlinkTarget != "" {
+			continue
+		}
+
+		path := pathFor(file)
+		var offset int64
+		for idx := range file.ChunkParts {
+			cr.add(partRef{
+				file:       file,
+				part:       &file.ChunkParts[idx],
+				path:       path,
+				fileOffset: offset,
+			})
+			offset += int64(file.ChunkParts[idx].Size)
+		}
+	}
+	return cr
+}
+
+// installPath returns where a manifest file lives inside dir.
+// Absolute names and names that climb out of dir are refused.
+func installPath(dir string, fileName string) (string, error) {
+	name := path.Clean(filepath.ToSlash(fileName))
+	if fileName == "" || name == "." || name == ".." || strings.HasPrefix(name, "../") ||
+		path.IsAbs(name) || filepath.IsAbs(filepath.FromSlash(name)) || filepath.VolumeName(filepath.FromSlash(name)) != "" {
+		return "", fmt.Errorf("%q: %w", fileName, ErrUnsafePath)
+	}
+	return filepath.Join(dir, filepath.FromSlash(name)), nil
+}
+
+// installPaths maps files to their installPath inside dir. Files below a symlink of
+// manifestFiles are refused too, writing them would follow the link out of dir.
+func installPaths(dir string, files []*File, manifestFiles ...[]*File) (map[*File]string, error) {
+	symlinks := map[string]struct{}{}
+	for _, list := range manifestFiles {
+		for _, file := range list {
+			if file.SymlinkTarget != "" {
+				symlinks[path.Clean(filepath.ToSlash(file.FileName))] = struct{}{}
+			}
+		}
+	}
+
+	paths := make(map[*File]string, len(files))
+	for _, file := range files {
+		p, err := installPath(dir, file.FileName)
+		if err != nil {
+			return nil, err
+		}
+
+		name := path.Clean(filepath.ToSlash(file.FileName))
+		for parent := path.Dir(name); parent != "."; parent = path.Dir(parent) {
+			if _, ok := symlinks[parent]; ok {
+				return nil, fmt.Errorf("%q is below symlink %q: %w", file.FileName, parent, ErrUnsafePath)
+			}
+		}
+		paths[file] = p
+	}
+	return paths, nil
+}
+
+// prepareFile replaces whatever is at path with an empty file of the right
+// size, or with a symlink.
+func prepareFile(path string, file *File) error {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if file.SymlinkTarget != "" {
+		return os.Symlink(file.SymlinkTarget, path)
+	}
+
+	fh, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = fh.Truncate(int64(file.Size()))
+	if closeErr := fh.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// writeChunkParts writes every part that references data into its file.
+func writeChunkParts(data []byte, refs []partRef) error {
+	for _, ref := range refs {
+		end := uint64(ref.part.Offset) + uint64(ref.part.Size)
+		if end > uint64(len(data)) {
+			return fmt.Errorf("chunk part of %s is out of range of chunk %s", ref.file.FileName, ref.part.ParentGUID)
+		}
+
+		fh, err := os.OpenFile(ref.path, os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		_, err = fh.WriteAt(data[ref.part.Offset:end], ref.fileOffset)
+		if closeErr := fh.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// finishFile applies the permissions from the meta flags to an assembled file.
+func finishFile(path string, file *File) error {
+	if file.SymlinkTarget != "" || runtime.GOOS == "windows" && !file.FileMetaFlags.IsReadOnly() {
+		return nil
+	}
+	return os.Chmod(path, file.FileMetaFlags.FileMode())
+}
+
+// fetchChunkData decodes chunks from src and hands their data to handle.
+// The chunks are fetched concurrently if src is a *Downloader, in which case handle must be safe for concurrent use.
+func fetchChunkData(ctx context.Context, src ChunkSource, chunks []*Chunk, handle func(c *Chunk, data []byte) error) error {
+	if downloader, ok := src.(*Downloader); ok {
+		return downloader.Download(ctx, chunks, func(c *Chunk, raw []byte) error {
+			data, err := DecodeChunk(raw, c)
+			if err != nil {
+				return err
+			}
+			return handle(c, data)
+		})
+	}
+
+	for _, chunk := range chunks {
+		data, err := ReadChunkData(ctx, src, chunk)
+		if err != nil {
+			return err
+		}
+
+		err = handle(chunk, data)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// assembleFiles rebuilds files at their paths, fetching every chunk they need exactly once.
+func assembleFiles(ctx context.Context, paths map[*File]string, files []*File, src ChunkSource) error {
+	return assembleFilesAt(ctx, files, src, func(file *File) string {
+		return paths[file]
+	})
+}
+
+// assembleFilesAt rebuilds files at the paths given by pathFor.
+func assembleFilesAt(ctx context.Context, files []*File, src ChunkSource, pathFor func(*File) string) error {
+	for _, file := range files {
+		err := prepareFile(pathFor(file), file)
+		if err != nil {
+			return err
+		}
+	}
+
+	cr := collectChunkRefs(files, pathFor)
+	err := fetchChunkData(ctx, src, cr.order, func(chunk *Chunk, data []byte) error {
+		return writeChunkParts(data, cr.refs[chunk.GUID])
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		err := finishFile(pathFor(file), file)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
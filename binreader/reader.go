@@ -6,6 +6,7 @@ import (
 	"io"
 	"io/ioutil"
 	"math"
+	"unicode/utf16"
 
 	"github.com/google/uuid"
 )
@@ -14,21 +15,93 @@ var (
 	ErrNegativeAmount = errors.New("negetive bytes count")
 )
 
+// size of the read-ahead buffer, big enough for any fixed-size value
+const bufferSize = 4096
+
+// NewReader returns a reader that buffers reads from r. Since it reads ahead, r is positioned
+// past the data that was consumed. Call Sync before using r directly again.
 func NewReader(r io.ReadSeeker, order binary.ByteOrder) *reader {
 	return &reader{
 		r:     r,
 		order: order,
+		buf:   make([]byte, bufferSize),
 	}
 }
 
 type reader struct {
 	r     io.ReadSeeker
 	order binary.ByteOrder
+
+	// buf[start:end] holds the data read from r that wasn't consumed yet
+	buf        []byte
+	start, end int
+}
+
+// fill makes sure at least n bytes are buffered, n has to be at most the buffer size
+func (r *reader) fill(n int) error {
+	if r.end-r.start >= n {
+		return nil
+	}
+
+	// move the unread data to the front to make room
+	if r.start > 0 {
+		r.end = copy(r.buf, r.buf[r.start:r.end])
+		r.start = 0
+	}
+
+	for r.end < n {
+		read, err := r.r.Read(r.buf[r.end:])
+		r.end += read
+		if r.end >= n {
+			return nil
+		}
+		if err == io.EOF {
+			if r.end == 0 {
+				return io.EOF
+			}
+			return io.ErrUnexpectedEOF
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// next consumes n buffered bytes and returns them, the slice is only valid until the next read
+func (r *reader) next(n int) ([]byte, error) {
+	err := r.fill(n)
+	if err != nil {
+		return nil, err
+	}
+
+	b := r.buf[r.start : r.start+n]
+	r.start += n
+	return b, nil
+}
+
+// Sync seeks the underlying reader back to the first byte that wasn't consumed
+// and drops the buffer, so that it can be used directly again.
+func (r *reader) Sync() error {
+	buffered := r.end - r.start
+	r.start, r.end = 0, 0
+	if buffered == 0 {
+		return nil
+	}
+
+	_, err := r.r.Seek(int64(-buffered), io.SeekCurrent)
+	return err
 }
 
 func (r *reader) ReadAll() ([]byte, error) {
-	b, err := ioutil.ReadAll(r.r)
-	return b, err
+	rest, err := ioutil.ReadAll(r.r)
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, 0, r.end-r.start+len(rest))
+	b = append(b, r.buf[r.start:r.end]...)
+	r.start, r.end = 0, 0
+	return append(b, rest...), nil
 }
 
 func (r *reader) ReadBytes(count int) (n int, out []byte, err error) {
@@ -41,13 +114,13 @@ func (r *reader) ReadBytes(count int) (n int, out []byte, err error) {
 	}
 
 	out = make([]byte, count)
-	n, err = io.ReadFull(r.r, out)
+	n, err = io.ReadFull(r, out)
 
 	return
 }
 
 func (r *reader) ReadUint8() (uint8, error) {
-	_, b, err := r.ReadBytes(1)
+	b, err := r.next(1)
 	if err != nil {
 		return 0, err
 	}
@@ -65,7 +138,7 @@ func (r *reader) ReadByte() (byte, error) {
 }
 
 func (r *reader) ReadUint16() (uint16, error) {
-	_, b, err := r.ReadBytes(2)
+	b, err := r.next(2)
 	if err != nil {
 		return 0, err
 	}
@@ -74,7 +147,7 @@ func (r *reader) ReadUint16() (uint16, error) {
 }
 
 func (r *reader) ReadUint32() (uint32, error) {
-	_, b, err := r.ReadBytes(4)
+	b, err := r.next(4)
 	if err != nil {
 		return 0, err
 	}
@@ -83,7 +156,7 @@ func (r *reader) ReadUint32() (uint32, error) {
 }
 
 func (r *reader) ReadUint64() (uint64, error) {
-	_, b, err := r.ReadBytes(8)
+	b, err := r.next(8)
 	if err != nil {
 		return 0, err
 	}
@@ -130,39 +203,122 @@ func (r *reader) ReadFloat64() (float64, error) {
 }
 
 func (r *reader) Read(p []byte) (n int, err error) {
-	return r.r.Read(p)
+	if r.start == r.end {
+		if len(p) >= len(r.buf) {
+			// no point in copying large reads through the buffer
+			return r.r.Read(p)
+		}
+
+		r.start, r.end = 0, 0
+		n, err = r.r.Read(r.buf)
+		r.end = n
+		if n == 0 {
+			return 0, err
+		}
+	}
+
+	n = copy(p, r.buf[r.start:r.end])
+	r.start += n
+	return n, nil
 }
 
 func (r *reader) Seek(offset int64, whence int) (int64, error) {
+	if whence == io.SeekCurrent {
+		// the underlying reader is ahead by the buffered data
+		offset -= int64(r.end - r.start)
+	}
+	r.start, r.end = 0, 0
+
 	i, err := r.r.Seek(offset, whence)
 	return i, err
 }
 
 func (r *reader) Peek(n int) ([]byte, error) {
-	bytesRead, b, err := r.ReadBytes(n)
+	if n < 0 {
+		return nil, ErrNegativeAmount
+	}
+	if n > len(r.buf) {
+		bytesRead, b, err := r.ReadBytes(n)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = r.Seek(int64(-bytesRead), io.SeekCurrent) // go back
+		return b, err
+	}
+
+	err := r.fill(n)
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = r.Seek(int64(-bytesRead), io.SeekCurrent) // go back
-	return b, err
+	b := make([]byte, n)
+	copy(b, r.buf[r.start:])
+	return b, nil
 }
 
-// reads a FString (null-terminated string starting with the length) from r
+// reads a FString (null-terminated string starting with the length) from r.
+// a negative length means the string is stored as UTF-16 with that many code units,
+// otherwise it's stored as ANSI, which is decoded as Latin-1 like Unreal does
 func (r *reader) ReadFString() (string, error) {
-	size, err := r.ReadUint32()
+	s, _, err := r.ReadFStringEncoding()
+	return s, err
+}
+
+// reads a FString like ReadFString and also reports whether it was stored as UTF-16
+func (r *reader) ReadFStringEncoding() (s string, isUTF16 bool, err error) {
+	size, err := r.ReadInt32()
 	if err != nil || size == 0 {
-		return "", err
+		return "", false, err
+	}
+	if size < 0 {
+		s, err = r.readFStringUTF16(-int(size))
+		return s, true, err
 	}
 
-	_, buf, err := r.ReadBytes(int(size))
+	var buf []byte
+	if int(size) <= len(r.buf) {
+		buf, err = r.next(int(size))
+	} else {
+		_, buf, err = r.ReadBytes(int(size))
+	}
 	if err != nil {
-		return "", err
+		return "", false, err
 	}
 	if buf[len(buf)-1] != 0x0 { // ensure it's null-terminated
+		return "", false, errors.New("string is not null terminated")
+	}
+	return decodeLatin1(buf[:len(buf)-1]), false, nil // avoid the null charecter while returning
+}
+
+// decodes ANSI bytes as Latin-1, where every byte is the code point of its character
+func decodeLatin1(b []byte) string {
+	for i := 0; i < len(b); i++ {
+		if b[i] >= 0x80 {
+			runes := make([]rune, len(b))
+			for j, c := range b {
+				runes[j] = rune(c)
+			}
+			return string(runes)
+		}
+	}
+	return string(b)
+}
+
+func (r *reader) readFStringUTF16(length int) (string, error) {
+	_, buf, err := r.ReadBytes(length * 2)
+	if err != nil {
+		return "", err
+	}
+
+	units := make([]uint16, length)
+	for i := range units {
+		units[i] = r.order.Uint16(buf[i*2:])
+	}
+	if units[length-1] != 0 {
 		return "", errors.New("string is not null terminated")
 	}
-	return string(buf[:len(buf)-1]), nil // avoid the null charecter while returning
+	return string(utf16.Decode(units[:length-1])), nil
 }
 
 // read an array of FStrings. they start wtih the length then the data
@@ -185,13 +341,12 @@ func (r *reader) ReadFStringArray() (out []string, err error) {
 
 // reads a GUID which is stored as 4 uint32 segments written in Big Endian
 func (r *reader) ReadGUID() (guid uuid.UUID, err error) {
-	data := make([]uint32, 4)
-	err = binary.Read(r, binary.BigEndian, &data)
+	b, err := r.next(16)
 	if err != nil {
 		return uuid.Nil, err
 	}
-	for i, v := range data {
-		binary.LittleEndian.PutUint32(guid[i*4:(i+1)*4], v)
+	for i := 0; i < 16; i += 4 {
+		binary.LittleEndian.PutUint32(guid[i:i+4], binary.BigEndian.Uint32(b[i:i+4]))
 	}
 	return
 }
@@ -0,0 +1,86 @@
+package binreader
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+func TestSync(t *testing.T) {
+	data := make([]byte, 3*bufferSize)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	f := bytes.NewReader(data)
+
+	r := NewReader(f, binary.LittleEndian)
+	if _, err := r.ReadUint32(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	// the underlying reader is right after the consumed bytes again
+	if pos, _ := f.Seek(0, io.SeekCurrent); pos != 4 {
+		t.Errorf("position after Sync = %d, want 4", pos)
+	}
+
+	r = NewReader(f, binary.LittleEndian)
+	if _, err := r.ReadUint8(); err != nil {
+		t.Fatal(err)
+	}
+	pos, err := r.Seek(10, io.SeekCurrent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos != 15 {
+		t.Errorf("Seek returned %d, want 15", pos)
+	}
+	b, err := r.ReadUint8()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b != 15 {
+		t.Errorf("read %d after seeking, want 15", b)
+	}
+}
+
+func TestReadAcrossBuffer(t *testing.T) {
+	data := make([]byte, 4*bufferSize+4)
+	for i := uint32(0); i < bufferSize; i++ {
+		binary.LittleEndian.PutUint32(data[i*4:], i)
+	}
+	// a string longer than the buffer
+	long := bytes.Repeat([]byte{'x'}, 2*bufferSize)
+	binary.LittleEndian.PutUint32(data[4*bufferSize:], uint32(len(long)+1))
+	data = append(append(data, long...), 0)
+
+	r := NewReader(bytes.NewReader(data), binary.LittleEndian)
+	for i := uint32(0); i < bufferSize; i++ {
+		v, err := r.ReadUint32()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != i {
+			t.Fatalf("value %d = %d", i, v)
+		}
+	}
+	s, err := r.ReadFString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != string(long) {
+		t.Errorf("long string has %d bytes, want %d", len(s), len(long))
+	}
+	if _, err := r.ReadUint8(); err != io.EOF {
+		t.Errorf("read past the end: %v", err)
+	}
+}
+
+func TestReadTruncated(t *testing.T) {
+	r := NewReader(bytes.NewReader([]byte{1, 2}), binary.LittleEndian)
+	if _, err := r.ReadUint32(); err != io.ErrUnexpectedEOF {
+		t.Errorf("truncated read = %v, want io.ErrUnexpectedEOF", err)
+	}
+}
@@ -0,0 +1,277 @@
+package binwriter
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"unicode/utf16"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNegativeOffset  = errors.New("negative offset")
+	ErrSectionTooLarge = errors.New("section is larger than 4GiB")
+)
+
+func NewWriter(w io.WriteSeeker, order binary.ByteOrder) *writer {
+	return &writer{
+		w:     w,
+		order: order,
+	}
+}
+
+type writer struct {
+	w     io.WriteSeeker
+	order binary.ByteOrder
+	buf   [8]byte
+}
+
+func (w *writer) Write(p []byte) (n int, err error) {
+	return w.w.Write(p)
+}
+
+func (w *writer) Seek(offset int64, whence int) (int64, error) {
+	return w.w.Seek(offset, whence)
+}
+
+func (w *writer) WriteBytes(b []byte) (int, error) {
+	return w.w.Write(b)
+}
+
+func (w *writer) WriteUint8(v uint8) error {
+	w.buf[0] = v
+	_, err := w.w.Write(w.buf[:1])
+	return err
+}
+
+func (w *writer) WriteBool(v bool) error {
+	if v {
+		return w.WriteUint8(1)
+	}
+	return w.WriteUint8(0)
+}
+
+func (w *writer) WriteByte(v byte) error {
+	return w.WriteUint8(v)
+}
+
+func (w *writer) WriteUint16(v uint16) error {
+	w.order.PutUint16(w.buf[:2], v)
+	_, err := w.w.Write(w.buf[:2])
+	return err
+}
+
+func (w *writer) WriteUint32(v uint32) error {
+	w.order.PutUint32(w.buf[:4], v)
+	_, err := w.w.Write(w.buf[:4])
+	return err
+}
+
+func (w *writer) WriteUint64(v uint64) error {
+	w.order.PutUint64(w.buf[:8], v)
+	_, err := w.w.Write(w.buf[:8])
+	return err
+}
+
+func (w *writer) WriteInt8(v int8) error {
+	return w.WriteUint8(uint8(v))
+}
+
+func (w *writer) WriteInt16(v int16) error {
+	return w.WriteUint16(uint16(v))
+}
+
+func (w *writer) WriteInt32(v int32) error {
+	return w.WriteUint32(uint32(v))
+}
+
+func (w *writer) WriteInt64(v int64) error {
+	return w.WriteUint64(uint64(v))
+}
+
+func (w *writer) WriteFloat32(v float32) error {
+	return w.WriteUint32(math.Float32bits(v))
+}
+
+func (w *writer) WriteFloat64(v float64) error {
+	return w.WriteUint64(math.Float64bits(v))
+}
+
+// isPureANSI reports whether s can be stored as an ANSI FString without losing characters.
+// Unlike Unreal's IsPureAnsi, which stops at 0x7F, this allows all of Latin-1: binreader decodes
+// ANSI FStrings as Latin-1, so such strings round-trip byte for byte instead of turning into UTF-16.
+func isPureANSI(s string) bool {
+	for _, c := range s {
+		if c > 0xFF {
+			return false
+		}
+	}
+	return true
+}
+
+// writes a FString (null-terminated string starting with the length) to w.
+// strings that aren't pure ANSI (Latin-1, see isPureANSI) are stored as UTF-16, see WriteFStringUTF16
+func (w *writer) WriteFString(s string) error {
+	if s == "" {
+		return w.WriteUint32(0)
+	}
+	if !isPureANSI(s) {
+		return w.WriteFStringUTF16(s)
+	}
+
+	buf := make([]byte, 0, len(s)+1)
+	for _, c := range s {
+		buf = append(buf, byte(c))
+	}
+	buf = append(buf, 0)
+
+	err := w.WriteInt32(int32(len(buf)))
+	if err != nil {
+		return err
+	}
+	_, err = w.w.Write(buf)
+	return err
+}
+
+// writes a FString as UTF-16, which is marked by a negative length counting the code units
+func (w *writer) WriteFStringUTF16(s string) error {
+	if s == "" {
+		return w.WriteUint32(0)
+	}
+
+	units := append(utf16.Encode([]rune(s)), 0)
+	err := w.WriteInt32(-int32(len(units)))
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, len(units)*2)
+	for i, unit := range units {
+		w.order.PutUint16(buf[i*2:], unit)
+	}
+	_, err = w.w.Write(buf)
+	return err
+}
+
+// writes an array of FStrings, starting with the length
+func (w *writer) WriteFStringArray(arr []string) error {
+	err := w.WriteUint32(uint32(len(arr)))
+	if err != nil {
+		return err
+	}
+
+	for _, s := range arr {
+		err = w.WriteFString(s)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writes a GUID as 4 uint32 segments in Big Endian, the inverse of binreader's ReadGUID
+func (w *writer) WriteGUID(guid uuid.UUID) error {
+	var data [16]byte
+	for i := 0; i < 4; i++ {
+		binary.BigEndian.PutUint32(data[i*4:], binary.LittleEndian.Uint32(guid[i*4:(i+1)*4]))
+	}
+	_, err := w.w.Write(data[:])
+	return err
+}
+
+// Section is a DataSize prefix that gets filled in once the section is written
+type Section struct {
+	start int64
+}
+
+// BeginSection writes a placeholder for the DataSize of a manifest section.
+// Write the rest of the section and call EndSection to fill it in.
+func (w *writer) BeginSection() (*Section, error) {
+	start, err := w.w.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.WriteUint32(0)
+	if err != nil {
+		return nil, err
+	}
+	return &Section{start: start}, nil
+}
+
+// EndSection back-patches the DataSize of s with the number of bytes written since
+// BeginSection, including the DataSize itself, and returns it.
+func (w *writer) EndSection(s *Section) (uint32, error) {
+	end, err := w.w.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	size := end - s.start
+	if size > math.MaxUint32 {
+		return 0, ErrSectionTooLarge
+	}
+
+	_, err = w.w.Seek(s.start, io.SeekStart)
+	if err != nil {
+		return 0, err
+	}
+	err = w.WriteUint32(uint32(size))
+	if err != nil {
+		return 0, err
+	}
+	_, err = w.w.Seek(end, io.SeekStart)
+	return uint32(size), err
+}
+
+// Buffer is an in-memory io.WriteSeeker for building binary data with a writer.
+// The zero value is an empty buffer ready to use.
+type Buffer struct {
+	data []byte
+	pos  int
+}
+
+func (b *Buffer) Write(p []byte) (int, error) {
+	end := b.pos + len(p)
+	if end > len(b.data) {
+		if end > cap(b.data) {
+			grown := make([]byte, len(b.data), end*2)
+			copy(grown, b.data)
+			b.data = grown
+		}
+		b.data = b.data[:end]
+	}
+	copy(b.data[b.pos:], p)
+	b.pos = end
+	return len(p), nil
+}
+
+// Seek sets the position of the next write, seeking past the end zero-fills the gap once written to.
+func (b *Buffer) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = int64(b.pos) + offset
+	case io.SeekEnd:
+		abs = int64(len(b.data)) + offset
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if abs < 0 {
+		return 0, ErrNegativeOffset
+	}
+	b.pos = int(abs)
+	return abs, nil
+}
+
+// Bytes returns the written data, it's only valid until the next write.
+func (b *Buffer) Bytes() []byte {
+	return b.data
+}
+
+func (b *Buffer) Len() int {
+	return len(b.data)
+}
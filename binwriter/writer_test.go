@@ -0,0 +1,159 @@
+package binwriter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/er-azh/egmanifest/binreader"
+	"github.com/google/uuid"
+)
+
+func TestRoundTrip(t *testing.T) {
+	guid := uuid.MustParse("0a1b2c3d-4e5f-6071-8293-a4b5c6d7e8f9")
+	strs := []string{"abc", "", "héllo", "日本 \U0001F600"}
+	long := strings.Repeat("x", 5000)
+
+	var buf Buffer
+	w := NewWriter(&buf, binary.LittleEndian)
+	section, err := w.BeginSection()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteUint8(0xAB)
+	w.WriteBool(true)
+	w.WriteUint16(0xBEEF)
+	w.WriteUint32(0xDEADBEEF)
+	w.WriteUint64(0x0102030405060708)
+	w.WriteInt32(-5)
+	w.WriteInt64(-6)
+	w.WriteFloat32(1.5)
+	w.WriteFloat64(-2.25)
+	w.WriteGUID(guid)
+	w.WriteFStringArray(strs)
+	w.WriteFStringUTF16("ascii")
+	w.WriteFString(long)
+	size, err := w.EndSection(section)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int(size) != buf.Len() {
+		t.Errorf("section size %d, wrote %d bytes", size, buf.Len())
+	}
+
+	r := binreader.NewReader(bytes.NewReader(buf.Bytes()), binary.LittleEndian)
+	check := func(name string, got interface{}, err error, want interface{}) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s = %v, want %v", name, got, want)
+		}
+	}
+
+	u32, err := r.ReadUint32()
+	check("section size", u32, err, size)
+	u8, err := r.ReadUint8()
+	check("uint8", u8, err, uint8(0xAB))
+	b, err := r.ReadBool()
+	check("bool", b, err, true)
+	u16, err := r.ReadUint16()
+	check("uint16", u16, err, uint16(0xBEEF))
+	u32, err = r.ReadUint32()
+	check("uint32", u32, err, uint32(0xDEADBEEF))
+	u64, err := r.ReadUint64()
+	check("uint64", u64, err, uint64(0x0102030405060708))
+	i32, err := r.ReadInt32()
+	check("int32", i32, err, int32(-5))
+	i64, err := r.ReadInt64()
+	check("int64", i64, err, int64(-6))
+	f32, err := r.ReadFloat32()
+	check("float32", f32, err, float32(1.5))
+	f64, err := r.ReadFloat64()
+	check("float64", f64, err, -2.25)
+	g, err := r.ReadGUID()
+	check("guid", g, err, guid)
+	arr, err := r.ReadFStringArray()
+	check("fstring array", arr, err, strs)
+	s, isUTF16, err := r.ReadFStringEncoding()
+	check("forced UTF-16 fstring", s, err, "ascii")
+	if !isUTF16 {
+		t.Errorf("WriteFStringUTF16 wasn't read back as UTF-16")
+	}
+	s, err = r.ReadFString()
+	check("long fstring", s, err, long)
+
+	if _, err := r.ReadUint8(); err != io.EOF {
+		t.Errorf("read past the section: %v", err)
+	}
+}
+
+func TestFStringEncoding(t *testing.T) {
+	tests := []struct {
+		s    string
+		want []byte
+	}{
+		// Latin-1 characters are stored as single ANSI bytes
+		{"aé", []byte{3, 0, 0, 0, 'a', 0xe9, 0}},
+		// anything beyond that is UTF-16 with a negative length
+		{"aĀ", []byte{0xfd, 0xff, 0xff, 0xff, 'a', 0, 0x00, 0x01, 0, 0}},
+		{"", []byte{0, 0, 0, 0}},
+	}
+	for _, test := range tests {
+		var buf Buffer
+		err := NewWriter(&buf, binary.LittleEndian).WriteFString(test.s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), test.want) {
+			t.Errorf("WriteFString(%q) = % x, want % x", test.s, buf.Bytes(), test.want)
+		}
+
+		s, err := binreader.NewReader(bytes.NewReader(buf.Bytes()), binary.LittleEndian).ReadFString()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s != test.s {
+			t.Errorf("read back %q, want %q", s, test.s)
+		}
+	}
+}
+
+func TestGUIDLayout(t *testing.T) {
+	guid := uuid.MustParse("00010203-0405-0607-0809-0a0b0c0d0e0f")
+
+	var buf Buffer
+	err := NewWriter(&buf, binary.LittleEndian).WriteGUID(guid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every uint32 of the GUID is stored big endian
+	want := []byte{3, 2, 1, 0, 7, 6, 5, 4, 11, 10, 9, 8, 15, 14, 13, 12}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("WriteGUID = % x, want % x", buf.Bytes(), want)
+	}
+}
+
+func TestBuffer(t *testing.T) {
+	var buf Buffer
+	buf.Write([]byte("hello"))
+	if _, err := buf.Seek(1, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	buf.Write([]byte("EL"))
+	if _, err := buf.Seek(2, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	buf.Write([]byte("!"))
+
+	if got, want := string(buf.Bytes()), "hELlo\x00\x00!"; got != want {
+		t.Errorf("buffer = %q, want %q", got, want)
+	}
+	if _, err := buf.Seek(-1, io.SeekStart); err != ErrNegativeOffset {
+		t.Errorf("negative seek = %v, want ErrNegativeOffset", err)
+	}
+}
@@ -0,0 +1,178 @@
+package egmanifest
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+var (
+	ErrNoManifestLocations = errors.New("build info has no manifest locations")
+)
+
+// BuildInfo is the response of the launcher's asset endpoints describing where to download a build.
+type BuildInfo struct {
+	Elements []BuildElement `json:"elements"`
+}
+
+type BuildElement struct {
+	AppName      string `json:"appName"`
+	LabelName    string `json:"labelName"`
+	BuildVersion string `json:"buildVersion"`
+	// hex SHA1 of the manifest file
+	Hash         string `json:"hash"`
+	UseSignedURL bool   `json:"useSignedUrl"`
+	// the same manifest hosted on different CDNs
+	Manifests []ManifestLocation `json:"manifests"`
+}
+
+// ManifestLocation is a manifest URL and the query parameters, usually signing tokens,
+// that have to be added to it and to every chunk URL of the build.
+type ManifestLocation struct {
+	URI         string      `json:"uri"`
+	QueryParams QueryParams `json:"queryParams"`
+}
+
+type QueryParam struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type QueryParams []QueryParam
+
+// queryEscape percent-encodes the bytes that aren't allowed in a URL query and the ones that would
+// end a parameter or the query. The values are signed as the launcher sends them, so everything else,
+// including '%' of already encoded values, is kept as-is.
+func queryEscape(s string) string {
+	var sb strings.Builder
+	for idx := 0; idx < len(s); idx++ {
+		c := s[idx]
+		if isQueryByte(c) {
+			sb.WriteByte(c)
+			continue
+		}
+		sb.WriteByte('%')
+		sb.WriteByte(upperhex[c>>4])
+		sb.WriteByte(upperhex[c&15])
+	}
+	return sb.String()
+}
+
+const upperhex = "0123456789ABCDEF"
+
+// isQueryByte reports whether c may appear unescaped in a query parameter, see RFC 3986.
+func isQueryByte(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("-._~!$'()*+,;=:@/?%", c) != -1
+}
+
+// Encode encodes the parameters as a URL query, keeping their order.
+// See queryEscape for how names and values are escaped.
+func (p QueryParams) Encode() string {
+	var sb strings.Builder
+	for idx, param := range p {
+		if idx != 0 {
+			sb.WriteByte('&')
+		}
+		sb.WriteString(queryEscape(param.Name))
+		sb.WriteByte('=')
+		sb.WriteString(queryEscape(param.Value))
+	}
+	return sb.String()
+}
+
+// ParseBuildInfo decodes a build info response.
+func ParseBuildInfo(data []byte) (*BuildInfo, error) {
+	var info BuildInfo
+	err := json.Unmarshal(data, &info)
+	if err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// withQuery appends an encoded query to rawURL, which may already have one.
+func withQuery(rawURL string, query string) string {
+	if query == "" {
+		return rawURL
+	}
+	if strings.Contains(rawURL, "?") {
+		return rawURL + "&" + query
+	}
+	return rawURL + "?" + query
+}
+
+// ManifestURL returns the URL to download the manifest from.
+func (l ManifestLocation) ManifestURL() string {
+	return withQuery(l.URI, l.QueryParams.Encode())
+}
+
+// query returns the query of the URI followed by the encoded QueryParams.
+// Both are needed for the data of the build, as either can carry the signature.
+func (l ManifestLocation) query() string {
+	var query string
+	if idx := strings.IndexByte(l.URI, '?'); idx != -1 {
+		query = l.URI[idx+1:]
+	}
+	params := l.QueryParams.Encode()
+	if query == "" || params == "" {
+		return query + params
+	}
+	return query + "&" + params
+}
+
+// CloudDir returns the CloudDir the manifest is in. It's a directory so the query of the URI is dropped,
+// DataURL, Mirror and ChunkSource add it to the URLs of the data.
+func (l ManifestLocation) CloudDir() string {
+	uri := l.URI
+	if idx := strings.IndexByte(uri, '?'); idx != -1 {
+		uri = uri[:idx]
+	}
+	if idx := strings.LastIndexByte(uri, '/'); idx != -1 {
+		uri = uri[:idx]
+	}
+	return uri
+}
+
+// DataDir returns the directory holding the data of manifest below CloudDir.
+func (l ManifestLocation) DataDir(manifest *BinaryManifest) string {
+	return l.CloudDir() + "/" + manifest.DataSubDir()
+}
+
+// DataURL returns the URL of the data of c, carrying the query parameters.
+func (l ManifestLocation) DataURL(manifest *BinaryManifest, c *Chunk) string {
+	return withQuery(manifest.GetDataURL(l.CloudDir(), c), l.query())
+}
+
+// Mirror returns the location as a Mirror for manifest.
+func (l ManifestLocation) Mirror(manifest *BinaryManifest) Mirror {
+	return Mirror{ChunksDir: l.DataDir(manifest), Query: l.query()}
+}
+
+// ChunkSource returns an HTTPChunkSource fetching the data of manifest from the location.
+func (l ManifestLocation) ChunkSource(manifest *BinaryManifest) *HTTPChunkSource {
+	return &HTTPChunkSource{
+		ChunksDir: l.DataDir(manifest),
+		Manifest:  manifest,
+		Query:     l.query(),
+	}
+}
+
+// MirrorSource returns a MirrorSource over all the locations of the element, in their order.
+func (e *BuildElement) MirrorSource(manifest *BinaryManifest) (*MirrorSource, error) {
+	if len(e.Manifests) == 0 {
+		return nil, ErrNoManifestLocations
+	}
+
+	mirrors := make([]Mirror, len(e.Manifests))
+	for idx, location := range e.Manifests {
+		mirrors[idx] = location.Mirror(manifest)
+	}
+
+	s := NewMirrorSource(mirrors)
+	s.Manifest = manifest
+	return s, nil
+}
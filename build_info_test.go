@@ -0,0 +1,133 @@
+package egmanifest
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// the query the launcher signed, exactly as it has to reach the CDN
+const testSignedQuery = "f_token=exp=1760000000~acl=/Builds/Test/CloudDir/*~hmac=9c1e0d3a5b7f2e4c6a8d0b1f3e5c7a9d&sig=a+b/c=="
+
+func TestQueryParamsEncode(t *testing.T) {
+	params := QueryParams{
+		{Name: "a", Value: "x&y#z"},
+		{Name: "b", Value: "~/+=%2F"},
+		{Name: "c d", Value: "1 2\n\"<>ü"},
+	}
+	if query, want := params.Encode(), "a=x%26y%23z&b=~/+=%2F&c%20d=1%202%0A%22%3C%3E%C3%BC"; query != want {
+		t.Errorf("Encode = %q, want %q", query, want)
+	}
+}
+
+func TestManifestLocationQuery(t *testing.T) {
+	manifest, _ := testManifest()
+	chunk := manifest.ChunkDataList.Chunks[0]
+	location := ManifestLocation{
+		URI:         "https://cdn.example.com/CloudDir/Test.manifest?sig=a+b/c==",
+		QueryParams: QueryParams{{Name: "token", Value: "t"}},
+	}
+
+	if dir, want := location.CloudDir(), "https://cdn.example.com/CloudDir"; dir != want {
+		t.Errorf("CloudDir = %q, want %q", dir, want)
+	}
+	if url, want := location.ManifestURL(), location.URI+"&token=t"; url != want {
+		t.Errorf("ManifestURL = %q, want %q", url, want)
+	}
+	want := location.DataDir(manifest) + "/" + manifest.DataPath(chunk) + "?sig=a+b/c==&token=t"
+	if url := location.DataURL(manifest, chunk); url != want {
+		t.Errorf("DataURL = %q, want %q", url, want)
+	}
+	src := location.ChunkSource(manifest)
+	if url := withQuery(chunkURL(src.ChunksDir, src.Manifest, chunk), src.Query); url != want {
+		t.Errorf("ChunkSource URL = %q, want %q", url, want)
+	}
+
+	location.QueryParams = nil
+	if query, want := location.Mirror(manifest).Query, "sig=a+b/c=="; query != want {
+		t.Errorf("Mirror query = %q, want %q", query, want)
+	}
+}
+
+func TestBuildInfoQuery(t *testing.T) {
+	manifest, src := testManifest()
+	manifestData := encodeTestManifest(manifest, true)
+
+	var mu sync.Mutex
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.URL.Path)
+		mu.Unlock()
+
+		if r.URL.RawQuery != testSignedQuery {
+			http.Error(w, "bad signature", http.StatusForbidden)
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, ".manifest") {
+			w.Write(manifestData)
+			return
+		}
+		for _, c := range manifest.ChunkDataList.Chunks {
+			if strings.HasSuffix(r.URL.Path, "/"+manifest.DataPath(c)) {
+				w.Write(src[c.GUID])
+				return
+			}
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	fixture, err := ioutil.ReadFile("testdata/build_info.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixture = bytes.ReplaceAll(fixture, []byte("https://download.epicgames.com"), []byte(server.URL))
+	info, err := ParseBuildInfo(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	element := &info.Elements[0]
+	location := element.Manifests[0]
+
+	resp, err := http.Get(location.ManifestURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("manifest request failed: %s", resp.Status)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseManifest(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chunks := parsed.ChunkDataList.Chunks
+	_, err = ReadChunkData(context.Background(), location.ChunkSource(parsed), chunks[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mirrors, err := element.MirrorSource(parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ReadChunkData(context.Background(), mirrors, chunks[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(requests) != 3 {
+		t.Errorf("made %d requests, want 3: %v", len(requests), requests)
+	}
+}
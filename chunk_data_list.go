@@ -6,6 +6,7 @@ import (
 	"io"
 
 	"github.com/er-azh/egmanifest/binreader"
+	"github.com/er-azh/egmanifest/binwriter"
 	"github.com/google/uuid"
 )
 
@@ -29,8 +30,54 @@ type Chunk struct {
 
 // gets the URL for a chunk.
 // example for chunksDir: http://epicgames-download1.akamaized.net/Builds/Fortnite/CloudDir/ChunksV4
+//
+// Deprecated: GetURL always uses the current naming and the stored group, which only matches
+// ChunksV4 directories. Use GetURLFor with the manifest's feature level, or BinaryManifest.GetDataURL.
 func (c *Chunk) GetURL(chunksDir string) string {
-	return fmt.Sprintf("%s/%02d/%016X_%X.chunk", chunksDir, c.Group, c.Hash, c.GUID[:])
+	return c.GetURLFor(chunksDir, EFeatureLevelLatest)
+}
+
+// FileName returns the name of the chunk file.
+func (c *Chunk) FileName() string {
+	return fmt.Sprintf("%016X_%X.chunk", c.Hash, c.GUID[:])
+}
+
+// DataGroup returns the group number of the chunk for a manifest of the given feature level.
+// Manifests before EFeatureLevelStoresDataGroupNumbers don't store it, so it's computed with ChunkGroup.
+func (c *Chunk) DataGroup(featureLevel EFeatureLevel) uint8 {
+	if featureLevel < EFeatureLevelStoresDataGroupNumbers {
+		return ChunkGroup(c.GUID)
+	}
+	return c.Group
+}
+
+// PathFor returns the path of the chunk relative to the ChunkSubDir of the feature level.
+// Before EFeatureLevelDataFileRenames chunk files were named after their GUID only.
+func (c *Chunk) PathFor(featureLevel EFeatureLevel) string {
+	group := c.DataGroup(featureLevel)
+	if featureLevel < EFeatureLevelDataFileRenames {
+		return fmt.Sprintf("%02d/%X.chunk", group, c.GUID[:])
+	}
+	return fmt.Sprintf("%02d/%s", group, c.FileName())
+}
+
+// GetURLFor is GetURL for a manifest of the given feature level, chunksDir has to match its ChunkSubDir.
+func (c *Chunk) GetURLFor(chunksDir string, featureLevel EFeatureLevel) string {
+	return fmt.Sprintf("%s/%s", chunksDir, c.PathFor(featureLevel))
+}
+
+func (c *Chunk) fileDataPath(group uint8) string {
+	return fmt.Sprintf("%02d/%X_%X.file", group, c.SHAHash[:], c.GUID[:])
+}
+
+// FileDataPathFor returns the path of the file data relative to the FileDataSubDir of the feature level.
+// Like chunks, file data was named after its GUID only before EFeatureLevelDataFileRenames.
+func (c *Chunk) FileDataPathFor(featureLevel EFeatureLevel) string {
+	group := c.DataGroup(featureLevel)
+	if featureLevel < EFeatureLevelDataFileRenames {
+		return fmt.Sprintf("%02d/%X.file", group, c.GUID[:])
+	}
+	return c.fileDataPath(group)
 }
 
 func ReadChunkDataList(f io.ReadSeeker) (*FChunkDataList, error) {
@@ -76,11 +123,10 @@ func ReadChunkDataList(f io.ReadSeeker) (*FChunkDataList, error) {
 	}
 
 	for _, chunk := range list.Chunks {
-		_, shaHash, err := reader.ReadBytes(20)
+		_, err = io.ReadFull(reader, chunk.SHAHash[:])
 		if err != nil {
 			return nil, err
 		}
-		copy(chunk.SHAHash[:], shaHash)
 	}
 
 	for _, chunk := range list.Chunks {
@@ -104,5 +150,51 @@ func ReadChunkDataList(f io.ReadSeeker) (*FChunkDataList, error) {
 		}
 	}
 
+	// leave f right after the section for the caller
+	err = reader.Sync()
+	if err != nil {
+		return nil, err
+	}
 	return &list, nil
 }
+
+// WriteChunkDataList writes list as a chunk data list section, the inverse of ReadChunkDataList,
+// and sets list.DataSize and list.Count to what was written.
+func WriteChunkDataList(w io.Writer, list *FChunkDataList) error {
+	// writes to a Buffer can't fail
+	var buf binwriter.Buffer
+	writer := binwriter.NewWriter(&buf, binary.LittleEndian)
+
+	section, _ := writer.BeginSection()
+	writer.WriteUint8(list.DataVersion)
+	writer.WriteUint32(uint32(len(list.Chunks)))
+	// like the reader, every field is stored for all the chunks before the next one
+	for _, chunk := range list.Chunks {
+		writer.WriteGUID(chunk.GUID)
+	}
+	for _, chunk := range list.Chunks {
+		writer.WriteUint64(chunk.Hash)
+	}
+	for _, chunk := range list.Chunks {
+		writer.WriteBytes(chunk.SHAHash[:])
+	}
+	for _, chunk := range list.Chunks {
+		writer.WriteUint8(chunk.Group)
+	}
+	for _, chunk := range list.Chunks {
+		writer.WriteUint32(chunk.WindowSize)
+	}
+	for _, chunk := range list.Chunks {
+		writer.WriteUint64(chunk.FileSize)
+	}
+
+	size, err := writer.EndSection(section)
+	if err != nil {
+		return err
+	}
+	list.DataSize = size
+	list.Count = uint32(len(list.Chunks))
+
+	_, err = w.Write(buf.Bytes())
+	return err
+}
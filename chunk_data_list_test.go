@@ -0,0 +1,38 @@
+package egmanifest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestDataPath(t *testing.T) {
+	chunk := &Chunk{
+		GUID:  uuid.MustParse("0a1b2c3d-4e5f-6071-8293-a4b5c6d7e8f9"),
+		Hash:  0x1122334455667788,
+		Group: 7,
+	}
+	chunk.SHAHash[0] = 0xAB
+	guid := fmt.Sprintf("%X", chunk.GUID[:])
+	oldGroup := fmt.Sprintf("%02d", ChunkGroup(chunk.GUID))
+
+	tests := []struct {
+		level      EFeatureLevel
+		isFileData bool
+		want       string
+	}{
+		{EFeatureLevelOriginal, false, "Chunks/" + oldGroup + "/" + guid + ".chunk"},
+		{EFeatureLevelOriginal, true, "Files/" + oldGroup + "/" + guid + ".file"},
+		{EFeatureLevelDataFileRenames, true, "FilesV2/" + oldGroup + "/" + fmt.Sprintf("%X_%s.file", chunk.SHAHash[:], guid)},
+		{EFeatureLevelLatest, false, "ChunksV4/07/" + chunk.FileName()},
+		{EFeatureLevelLatest, true, "FilesV2/07/" + fmt.Sprintf("%X_%s.file", chunk.SHAHash[:], guid)},
+	}
+	for _, test := range tests {
+		manifest := &BinaryManifest{Metadata: &FManifestMeta{FeatureLevel: test.level, IsFileData: test.isFileData}}
+		path := manifest.DataSubDir() + "/" + manifest.DataPath(chunk)
+		if path != test.want {
+			t.Errorf("%s, file data %v: got %s, want %s", test.level, test.isFileData, path, test.want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package egmanifest
+
+import (
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+// ChunkRef is a range of a file that's stored in a chunk.
+type ChunkRef struct {
+	File       *File
+	FileOffset uint64
+	// offset of the data inside the decompressed chunk
+	ChunkOffset uint32
+	Size        uint32
+}
+
+// ChunkIndex maps chunks to the file ranges they hold, the reverse of File.ChunkParts.
+type ChunkIndex struct {
+	refs map[uuid.UUID][]ChunkRef
+}
+
+// NewChunkIndex indexes the chunk parts of files. Symlinks have no data and are skipped.
+func NewChunkIndex(files []*File) *ChunkIndex {
+	idx := &ChunkIndex{refs: map[uuid.UUID][]ChunkRef{}}
+	for _, file := range files {
+		if file.SymlinkTarget != "" {
+			continue
+		}
+
+		var offset uint64
+		for _, part := range file.ChunkParts {
+			idx.refs[part.ParentGUID] = append(idx.refs[part.ParentGUID], ChunkRef{
+				File:        file,
+				FileOffset:  offset,
+				ChunkOffset: part.Offset,
+				Size:        part.Size,
+			})
+			offset += uint64(part.Size)
+		}
+	}
+	return idx
+}
+
+// ChunkIndex builds a ChunkIndex over all the files of the manifest.
+func (m *BinaryManifest) ChunkIndex() *ChunkIndex {
+	return NewChunkIndex(m.FileManifestList.Files())
+}
+
+// Refs returns every file range stored in the chunk, in file order.
+func (idx *ChunkIndex) Refs(guid uuid.UUID) []ChunkRef {
+	return idx.refs[guid]
+}
+
+// Files returns the files that have data in the chunk, without duplicates.
+func (idx *ChunkIndex) Files(guid uuid.UUID) []*File {
+	var files []*File
+	seen := map[*File]struct{}{}
+	for _, ref := range idx.refs[guid] {
+		if _, ok := seen[ref.File]; ok {
+			continue
+		}
+		seen[ref.File] = struct{}{}
+		files = append(files, ref.File)
+	}
+	return files
+}
+
+// InstallTags returns the sorted install tags of the files with data in the chunk.
+// required is true if any of them is installed regardless of the selected tags.
+func (idx *ChunkIndex) InstallTags(guid uuid.UUID) (tags []string, required bool) {
+	seen := map[string]struct{}{}
+	for _, file := range idx.Files(guid) {
+		if isAlwaysInstalled(file) {
+			required = true
+		}
+		for _, tag := range file.InstallTags {
+			if tag == "" {
+				continue
+			}
+			if _, ok := seen[tag]; !ok {
+				seen[tag] = struct{}{}
+				tags = append(tags, tag)
+			}
+		}
+	}
+	sort.Strings(tags)
+	return tags, required
+}
@@ -0,0 +1,67 @@
+package egmanifest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestChunkIndex(t *testing.T) {
+	manifest, _ := testManifest()
+	c0, c1 := manifest.ChunkDataList.Chunks[0], manifest.ChunkDataList.Chunks[1]
+	list := manifest.FileManifestList
+	// a file using two ranges of the same chunk
+	list.FileManifestList = append(list.FileManifestList, File{
+		FileName:    "data/c.pak",
+		InstallTags: []string{"de"},
+		ChunkParts: []ChunkPart{
+			{ParentGUID: c1.GUID, Chunk: c1, Offset: 0, Size: 100},
+			{ParentGUID: c1.GUID, Chunk: c1, Offset: 900, Size: 100},
+		},
+	})
+	files := list.FileManifestList
+	idx := manifest.ChunkIndex()
+
+	wantRefs := map[uuid.UUID][]ChunkRef{
+		c0.GUID: {
+			{File: &files[0], FileOffset: 0, ChunkOffset: 0, Size: 600},
+			{File: &files[1], FileOffset: 0, ChunkOffset: 600, Size: 400},
+		},
+		c1.GUID: {
+			{File: &files[0], FileOffset: 600, ChunkOffset: 100, Size: 300},
+			{File: &files[2], FileOffset: 0, ChunkOffset: 500, Size: 500},
+			{File: &files[4], FileOffset: 0, ChunkOffset: 0, Size: 100},
+			{File: &files[4], FileOffset: 100, ChunkOffset: 900, Size: 100},
+		},
+	}
+	for guid, want := range wantRefs {
+		if refs := idx.Refs(guid); !reflect.DeepEqual(refs, want) {
+			t.Errorf("Refs(%s) = %v, want %v", guid, refs, want)
+		}
+	}
+
+	// files sharing a chunk are listed once each
+	if names := fileNames(idx.Files(c1.GUID)); !reflect.DeepEqual(names, []string{"bin/game.exe", "data/b.pak", "data/c.pak"}) {
+		t.Errorf("Files(c1) = %v", names)
+	}
+	tags, required := idx.InstallTags(c1.GUID)
+	if !reflect.DeepEqual(tags, []string{"de", "fr"}) || !required {
+		t.Errorf("InstallTags(c1) = %v, %v, want [de fr], true", tags, required)
+	}
+
+	// a chunk only tagged files use isn't required
+	list.FileManifestList[0].ChunkParts = nil
+	tags, required = manifest.ChunkIndex().InstallTags(c0.GUID)
+	if !reflect.DeepEqual(tags, []string{"hd"}) || required {
+		t.Errorf("InstallTags(c0) = %v, %v, want [hd], false", tags, required)
+	}
+
+	missing := uuid.New()
+	if refs, files := idx.Refs(missing), idx.Files(missing); refs != nil || files != nil {
+		t.Errorf("unknown chunk has refs %v and files %v", refs, files)
+	}
+	if tags, required := idx.InstallTags(missing); tags != nil || required {
+		t.Errorf("InstallTags of an unknown chunk = %v, %v", tags, required)
+	}
+}
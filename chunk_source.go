@@ -0,0 +1,117 @@
+package egmanifest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/er-azh/egmanifest/chunks"
+)
+
+var (
+	ErrChunkHashMismatch = errors.New("chunk data does not match its SHA hash")
+)
+
+// ChunkSource provides the chunk files referenced by a manifest.
+type ChunkSource interface {
+	// FetchChunk returns the raw chunk file for c, header included.
+	FetchChunk(ctx context.Context, c *Chunk) ([]byte, error)
+}
+
+// ChunkOpener is implemented by chunk sources that can stream chunk files.
+type ChunkOpener interface {
+	ChunkSource
+	// OpenChunk returns a reader over the raw chunk file for c and its size, -1 if unknown.
+	OpenChunk(ctx context.Context, c *Chunk) (io.ReadCloser, int64, error)
+}
+
+// HTTPChunkSource fetches chunks from a CloudDir over HTTP.
+type HTTPChunkSource struct {
+	// example: http://epicgames-download1.akamaized.net/Builds/Fortnite/CloudDir/ChunksV4
+	ChunksDir string
+	// http.DefaultClient is used if nil
+	Client *http.Client
+	// if set, chunk URLs follow the naming of the manifest's feature level and data files, ChunksDir must
+	// then point to the manifest's DataSubDir. Without it chunks are named like in ChunksV4, which only
+	// matches builds since EFeatureLevelStoresDataGroupNumbers, so set it for older and file data builds.
+	Manifest *BinaryManifest
+	// encoded query added to every chunk URL, e.g. the signing tokens of a ManifestLocation
+	Query string
+}
+
+// chunkURL returns the URL of c below dir, following the naming of manifest if it's set
+// and the current one otherwise.
+func chunkURL(dir string, manifest *BinaryManifest, c *Chunk) string {
+	if manifest == nil {
+		return c.GetURLFor(dir, EFeatureLevelLatest)
+	}
+	return dir + "/" + manifest.DataPath(c)
+}
+
+func (s *HTTPChunkSource) OpenChunk(ctx context.Context, c *Chunk) (io.ReadCloser, int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, withQuery(chunkURL(s.ChunksDir, s.Manifest, c), s.Query), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, 0, fmt.Errorf("fetching chunk %s: unexpected status %s", c.GUID, resp.Status)
+	}
+
+	return resp.Body, resp.ContentLength, nil
+}
+
+func (s *HTTPChunkSource) FetchChunk(ctx context.Context, c *Chunk) ([]byte, error) {
+	body, _, err := s.OpenChunk(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	return ioutil.ReadAll(body)
+}
+
+// ReadChunkData fetches c from src and returns its decoded data after checking it against c.SHAHash.
+func ReadChunkData(ctx context.Context, src ChunkSource, c *Chunk) ([]byte, error) {
+	raw, err := src.FetchChunk(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	return DecodeChunk(raw, c)
+}
+
+// DecodeChunk decodes a raw chunk file belonging to c and checks it against c.SHAHash.
+func DecodeChunk(raw []byte, c *Chunk) ([]byte, error) {
+	reader, err := chunks.ParseChunk(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("chunk %s: %w", c.GUID, err)
+	}
+
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("chunk %s: %w", c.GUID, err)
+	}
+
+	// older manifests don't store the SHA hash
+	if c.SHAHash != [20]byte{} && sha1.Sum(data) != c.SHAHash {
+		return nil, fmt.Errorf("chunk %s: %w", c.GUID, ErrChunkHashMismatch)
+	}
+	return data, nil
+}
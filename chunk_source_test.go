@@ -0,0 +1,77 @@
+package egmanifest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPChunkSourceFeatureLevels(t *testing.T) {
+	manifest, src := testManifest()
+	chunk := manifest.ChunkDataList.Chunks[1]
+	// older builds don't store the group, make sure it differs from the computed one
+	chunk.Group = (ChunkGroup(chunk.GUID) + 1) % 100
+	group := fmt.Sprintf("%02d", chunk.Group)
+	guid := fmt.Sprintf("%X", chunk.GUID[:])
+	named := fmt.Sprintf("%016X_%s.chunk", chunk.Hash, guid)
+	oldGroup := fmt.Sprintf("%02d", ChunkGroup(chunk.GUID))
+
+	tests := []struct {
+		level EFeatureLevel
+		path  string
+	}{
+		{EFeatureLevelOriginal, "/Chunks/" + oldGroup + "/" + guid + ".chunk"},
+		{EFeatureLevelDataFileRenames, "/ChunksV2/" + oldGroup + "/" + named},
+		{EFeatureLevelChunkCompressionSupport, "/ChunksV3/" + group + "/" + named},
+		{EFeatureLevelLatest, "/ChunksV4/" + group + "/" + named},
+	}
+	for _, test := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != test.path {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write(src[chunk.GUID])
+		}))
+
+		meta := *manifest.Metadata
+		meta.FeatureLevel = test.level
+		old := *manifest
+		old.Metadata = &meta
+
+		httpSrc := &HTTPChunkSource{ChunksDir: server.URL + "/" + old.DataSubDir(), Manifest: &old}
+		_, err := ReadChunkData(context.Background(), httpSrc, chunk)
+		if err != nil {
+			t.Errorf("%s: %v", test.level, err)
+		}
+
+		// without the manifest only the current naming can be used
+		httpSrc.Manifest = nil
+		_, err = httpSrc.FetchChunk(context.Background(), chunk)
+		if ok := err == nil; ok != (test.level >= EFeatureLevelStoresDataGroupNumbers) {
+			t.Errorf("%s: fetching without the manifest = %v", test.level, err)
+		}
+		server.Close()
+	}
+}
+
+func TestCloudDirServerAddChunks(t *testing.T) {
+	manifest, src := testManifest()
+	chunks := manifest.ChunkDataList.Chunks
+
+	cloudDir := NewCloudDirServer(src)
+	cloudDir.AddChunks(EFeatureLevelOriginal, chunks)
+	server := httptest.NewServer(cloudDir)
+	defer server.Close()
+
+	manifest.Metadata.FeatureLevel = EFeatureLevelOriginal
+	httpSrc := &HTTPChunkSource{ChunksDir: server.URL + "/" + manifest.DataSubDir(), Manifest: manifest}
+	for _, chunk := range chunks {
+		_, err := ReadChunkData(context.Background(), httpSrc, chunk)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
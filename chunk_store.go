@@ -0,0 +1,331 @@
+package egmanifest
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrChunkNotFound = errors.New("chunk not found")
+)
+
+// staleTempAge is how old a temporary file left by Put has to be before OpenChunkStore deletes it,
+// younger ones may belong to a Put of another process that's still running.
+const staleTempAge = time.Hour
+
+// ChunkStore is a content addressed on-disk chunk cache, laid out like a CloudDir ChunksV4 directory.
+// It's safe to share a store directory between processes: files are written atomically and every
+// read is checked against the manifest's hashes.
+type ChunkStore struct {
+	dir string
+	// the store evicts the least recently used chunks to stay under this size, 0 means unlimited
+	MaxSize int64
+	// OnPutError is called when a chunk fetched through the store can't be stored, the fetch
+	// itself still succeeds. It's called from the fetching goroutine and may be nil.
+	OnPutError func(c *Chunk, err error)
+
+	mu      sync.Mutex
+	size    int64
+	lru     *list.List // of *storeEntry, most recently used first
+	entries map[string]*list.Element
+}
+
+type storeEntry struct {
+	path string
+	size int64
+}
+
+// OpenChunkStore opens the chunk store in dir, creating it if needed.
+func OpenChunkStore(dir string, maxSize int64) (*ChunkStore, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &ChunkStore{
+		dir:     dir,
+		MaxSize: maxSize,
+		lru:     list.New(),
+		entries: map[string]*list.Element{},
+	}
+
+	type found struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var chunks []found
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		isTemp := strings.HasPrefix(d.Name(), ".tmp-")
+		if !isTemp && !strings.HasSuffix(path, ".chunk") {
+			return nil
+		}
+
+		info, err := d.Info()
+		if os.IsNotExist(err) {
+			// renamed or removed by another process in the meantime
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if isTemp {
+			if time.Since(info.ModTime()) < staleTempAge {
+				return nil
+			}
+			// left behind by an interrupted Put
+			err = os.Remove(path)
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		chunks = append(chunks, found{path: path, size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the modification time doubles as the last access time
+	sort.Slice(chunks, func(i, j int) bool {
+		return chunks[i].modTime.After(chunks[j].modTime)
+	})
+	for _, chunk := range chunks {
+		s.entries[chunk.path] = s.lru.PushBack(&storeEntry{path: chunk.path, size: chunk.size})
+		s.size += chunk.size
+	}
+
+	return s, nil
+}
+
+// Dir returns the directory of the store.
+func (s *ChunkStore) Dir() string {
+	return s.dir
+}
+
+// Size returns the total size of the chunks in the store.
+func (s *ChunkStore) Size() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
+}
+
+// path returns where c is stored. Chunks of every build use the ChunksV4 naming,
+// whose hash and GUID make it unique whatever the feature level of the build.
+func (s *ChunkStore) path(c *Chunk) string {
+	return filepath.Join(s.dir, filepath.FromSlash(c.PathFor(EFeatureLevelLatest)))
+}
+
+// Has reports whether c is in the store, without checking its integrity.
+func (s *ChunkStore) Has(c *Chunk) bool {
+	_, err := os.Stat(s.path(c))
+	return err == nil
+}
+
+// FetchChunk reads c from the store. A chunk that fails the integrity checks is
+// removed and reported as not found.
+func (s *ChunkStore) FetchChunk(ctx context.Context, c *Chunk) ([]byte, error) {
+	path := s.path(c)
+	raw, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		s.forget(path)
+		return nil, fmt.Errorf("chunk %s: %w", c.GUID, ErrChunkNotFound)
+	} else if err != nil {
+		return nil, err
+	}
+
+	err = checkChunk(raw, c)
+	if err != nil {
+		s.remove(path)
+		return nil, fmt.Errorf("chunk %s was corrupt (%v): %w", c.GUID, err, ErrChunkNotFound)
+	}
+
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	s.touch(path, int64(len(raw)))
+	return raw, nil
+}
+
+// Put adds the raw chunk file of c to the store, evicting old chunks if the store grows over MaxSize.
+func (s *ChunkStore) Put(c *Chunk, raw []byte) error {
+	err := checkChunk(raw, c)
+	if err != nil {
+		return err
+	}
+
+	path := s.path(c)
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(raw)
+	if err == nil {
+		// TempFile creates files only their owner can read
+		err = tmp.Chmod(0644)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	s.touch(path, int64(len(raw)))
+	s.evict()
+	return nil
+}
+
+// Remove deletes c from the store.
+func (s *ChunkStore) Remove(c *Chunk) error {
+	return s.remove(s.path(c))
+}
+
+// GC removes every chunk that isn't referenced by one of manifests and
+// returns how many chunks and bytes were freed.
+func (s *ChunkStore) GC(manifests ...*BinaryManifest) (int, int64, error) {
+	referenced := map[string]struct{}{}
+	for _, manifest := range manifests {
+		for _, chunk := range manifest.ChunkDataList.Chunks {
+			referenced[s.path(chunk)] = struct{}{}
+		}
+	}
+
+	s.mu.Lock()
+	var unreferenced []*storeEntry
+	for path, elem := range s.entries {
+		if _, ok := referenced[path]; !ok {
+			unreferenced = append(unreferenced, elem.Value.(*storeEntry))
+		}
+	}
+	s.mu.Unlock()
+
+	var (
+		removed int
+		freed   int64
+	)
+	for _, entry := range unreferenced {
+		err := s.remove(entry.path)
+		if err != nil {
+			return removed, freed, err
+		}
+		removed++
+		freed += entry.size
+	}
+	return removed, freed, nil
+}
+
+// Through returns a ChunkSource that reads from the store and falls back to upstream,
+// storing what it fetches from there. Chunks the store fails to read are fetched from upstream
+// too, and errors storing a chunk are reported to OnPutError instead of failing the fetch.
+func (s *ChunkStore) Through(upstream ChunkSource) ChunkSource {
+	return &cachedSource{store: s, upstream: upstream}
+}
+
+// checkChunk checks a raw chunk file against the manifest.
+func checkChunk(raw []byte, c *Chunk) error {
+	if c.FileSize != 0 && uint64(len(raw)) != c.FileSize {
+		return fmt.Errorf("chunk %s: %w", c.GUID, ErrChunkSizeMismatch)
+	}
+	_, err := DecodeChunk(raw, c)
+	return err
+}
+
+// touch marks path as the most recently used chunk.
+func (s *ChunkStore) touch(path string, size int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.entries[path]; ok {
+		entry := elem.Value.(*storeEntry)
+		s.size += size - entry.size
+		entry.size = size
+		s.lru.MoveToFront(elem)
+		return
+	}
+	s.entries[path] = s.lru.PushFront(&storeEntry{path: path, size: size})
+	s.size += size
+}
+
+func (s *ChunkStore) forget(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.entries[path]; ok {
+		s.size -= elem.Value.(*storeEntry).size
+		s.lru.Remove(elem)
+		delete(s.entries, path)
+	}
+}
+
+func (s *ChunkStore) remove(path string) error {
+	s.forget(path)
+	err := os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// evict removes the least recently used chunks until the store fits in MaxSize.
+func (s *ChunkStore) evict() {
+	if s.MaxSize <= 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.size > s.MaxSize && s.lru.Len() > 1 {
+		elem := s.lru.Back()
+		entry := elem.Value.(*storeEntry)
+		s.size -= entry.size
+		s.lru.Remove(elem)
+		delete(s.entries, entry.path)
+		os.Remove(entry.path)
+	}
+}
+
+type cachedSource struct {
+	store    *ChunkStore
+	upstream ChunkSource
+}
+
+func (s *cachedSource) FetchChunk(ctx context.Context, c *Chunk) ([]byte, error) {
+	// the store is only a cache, whatever keeps it from serving the chunk upstream still can
+	raw, err := s.store.FetchChunk(ctx, c)
+	if err == nil {
+		return raw, nil
+	}
+
+	raw, err = s.upstream.FetchChunk(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	// the data is good even if it couldn't be cached
+	err = s.store.Put(c, raw)
+	if err != nil && s.store.OnPutError != nil {
+		s.store.OnPutError(c, err)
+	}
+	return raw, nil
+}
@@ -0,0 +1,200 @@
+package egmanifest
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestChunkStore(t *testing.T) {
+	manifest, src := testManifest()
+	store, err := OpenChunkStore(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counter := &countingSource{memSource: src}
+	through := store.Through(counter)
+	for i := 0; i < 2; i++ {
+		for _, c := range manifest.ChunkDataList.Chunks {
+			_, err := ReadChunkData(context.Background(), through, c)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if counter.fetched != 2 {
+		t.Errorf("fetched %d chunks, want 2", counter.fetched)
+	}
+
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(store.path(manifest.ChunkDataList.Chunks[0]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if perm := info.Mode().Perm(); perm != 0644 {
+			t.Errorf("stored chunk has mode %v, want 0644", perm)
+		}
+	}
+}
+
+func TestChunkStoreTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	fresh := filepath.Join(dir, ".tmp-fresh")
+	stale := filepath.Join(dir, ".tmp-stale")
+	for _, path := range []string{fresh, stale} {
+		err := ioutil.WriteFile(path, []byte("partial"), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-2 * staleTempAge)
+	err := os.Chtimes(stale, old, old)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = OpenChunkStore(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(fresh); err != nil {
+		t.Errorf("a temporary file of a running Put was removed: %v", err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("a stale temporary file was kept")
+	}
+}
+
+// randomChunks encodes n chunks of incompressible data, so that their files have the same size.
+func randomChunks(t *testing.T, n int) ([]*Chunk, memSource) {
+	src := memSource{}
+	var chunks []*Chunk
+	for i := 0; i < n; i++ {
+		data := make([]byte, 1000)
+		rand.Read(data)
+		guid := uuid.New()
+		raw, err := EncodeChunk(guid, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		src[guid] = raw
+		chunks = append(chunks, &Chunk{GUID: guid, Hash: RollingHash(data), SHAHash: sha1.Sum(data), Group: ChunkGroup(guid), FileSize: uint64(len(raw))})
+	}
+	return chunks, src
+}
+
+func TestChunkStoreEviction(t *testing.T) {
+	chunks, src := randomChunks(t, 3)
+	size := int64(chunks[0].FileSize)
+	store, err := OpenChunkStore(t.TempDir(), 2*size+size/2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range chunks[:2] {
+		err = store.Put(c, src[c.GUID])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// reading the first chunk makes the second one the least recently used
+	_, err = store.FetchChunk(context.Background(), chunks[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.Put(chunks[2], src[chunks[2].GUID])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for idx, want := range []bool{true, false, true} {
+		if has := store.Has(chunks[idx]); has != want {
+			t.Errorf("chunk %d: in the store %v, want %v", idx, has, want)
+		}
+	}
+	if store.Size() != 2*size {
+		t.Errorf("store size %d, want %d", store.Size(), 2*size)
+	}
+}
+
+func TestChunkStoreGC(t *testing.T) {
+	chunks, src := randomChunks(t, 3)
+	store, err := OpenChunkStore(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range chunks {
+		err = store.Put(c, src[c.GUID])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	manifest := &BinaryManifest{ChunkDataList: &FChunkDataList{Chunks: []*Chunk{chunks[0], chunks[2]}}}
+	removed, freed, err := store.GC(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 1 || freed != int64(chunks[1].FileSize) {
+		t.Errorf("GC removed %d chunks and %d bytes, want 1 and %d", removed, freed, chunks[1].FileSize)
+	}
+	for idx, want := range []bool{true, false, true} {
+		if has := store.Has(chunks[idx]); has != want {
+			t.Errorf("chunk %d: in the store %v, want %v", idx, has, want)
+		}
+	}
+
+	// chunks found when opening the store are collected too
+	store, err = OpenChunkStore(store.Dir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	removed, _, err = store.GC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 2 || store.Size() != 0 {
+		t.Errorf("GC without manifests removed %d chunks and left %d bytes, want 2 and none", removed, store.Size())
+	}
+}
+
+func TestChunkStorePutError(t *testing.T) {
+	manifest, src := testManifest()
+	dir := filepath.Join(t.TempDir(), "store")
+	store, err := OpenChunkStore(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var putErrors int
+	store.OnPutError = func(c *Chunk, err error) {
+		putErrors++
+	}
+
+	// nothing can be created below a file
+	err = os.RemoveAll(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(dir, nil, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chunk := manifest.ChunkDataList.Chunks[0]
+	_, err = ReadChunkData(context.Background(), store.Through(src), chunk)
+	if err != nil {
+		t.Errorf("fetch failed because the chunk couldn't be stored: %v", err)
+	}
+	if putErrors != 1 {
+		t.Errorf("%d Put errors reported, want 1", putErrors)
+	}
+}
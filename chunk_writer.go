@@ -0,0 +1,111 @@
+package egmanifest
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"hash/crc32"
+	"math/bits"
+
+	"github.com/er-azh/egmanifest/binwriter"
+	"github.com/er-azh/egmanifest/chunks"
+	"github.com/google/uuid"
+)
+
+// ChunkWriter receives the chunk files produced by an import. *ChunkStore implements it.
+type ChunkWriter interface {
+	Put(c *Chunk, raw []byte) error
+}
+
+const (
+	// the window size used by BuildPatchServices
+	DefaultWindowSize = 1024 * 1024
+
+	chunkHeaderVersion = 3
+	chunkHeaderSize    = 66
+
+	// both the rolling hash and SHA1 are stored
+	chunkHashTypes = 0x03
+)
+
+// rollingHashTable is the table of FRollingHash, built from the CRC-64 ECMA polynomial.
+var rollingHashTable = func() (table [256]uint64) {
+	const poly = 0xC96C5795D7870F42
+	for idx := range table {
+		val := uint64(idx)
+		for i := 0; i < 8; i++ {
+			if val&1 == 1 {
+				val = val>>1 ^ poly
+			} else {
+				val >>= 1
+			}
+		}
+		table[idx] = val
+	}
+	return
+}()
+
+// RollingHash computes the BuildPatchServices rolling hash of data, which is what Chunk.Hash holds.
+func RollingHash(data []byte) uint64 {
+	var hash uint64
+	for _, b := range data {
+		hash = bits.RotateLeft64(hash, 1) ^ rollingHashTable[b]
+	}
+	return hash
+}
+
+// guidBytes returns the GUID in the layout Unreal keeps it in memory, four little endian uint32s.
+func guidBytes(guid uuid.UUID) []byte {
+	out := make([]byte, 16)
+	for i := 0; i < 4; i++ {
+		binary.LittleEndian.PutUint32(out[i*4:], binary.BigEndian.Uint32(guid[i*4:]))
+	}
+	return out
+}
+
+// ChunkGroup computes the group number of a chunk the way BuildPatchServices does,
+// the CRC32 of its GUID modulo 100.
+func ChunkGroup(guid uuid.UUID) uint8 {
+	return uint8(crc32.ChecksumIEEE(guidBytes(guid)) % 100)
+}
+
+// EncodeChunk builds a chunk file holding data, compressing it if that makes it smaller.
+func EncodeChunk(guid uuid.UUID, data []byte) ([]byte, error) {
+	payload := data
+	storedAs := chunks.ChunkStoredAsPlaintext
+
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	_, err := zw.Write(data)
+	if err != nil {
+		return nil, err
+	}
+	err = zw.Close()
+	if err != nil {
+		return nil, err
+	}
+	if compressed.Len() < len(data) {
+		payload = compressed.Bytes()
+		storedAs = chunks.ChunkStoredAsCompressed
+	}
+
+	shaHash := sha1.Sum(data)
+
+	// writes to a Buffer can't fail
+	var out binwriter.Buffer
+	w := binwriter.NewWriter(&out, binary.LittleEndian)
+	w.WriteUint32(chunks.ChunkHeaderMagic)
+	w.WriteUint32(chunkHeaderVersion)
+	w.WriteUint32(chunkHeaderSize)
+	w.WriteUint32(uint32(len(payload)))
+	w.WriteGUID(guid)
+	w.WriteUint64(RollingHash(data))
+	w.WriteUint8(uint8(storedAs))
+	w.WriteBytes(shaHash[:])
+	w.WriteUint8(chunkHashTypes)
+	w.WriteUint32(uint32(len(data)))
+	w.WriteBytes(payload)
+
+	return out.Bytes(), nil
+}
@@ -0,0 +1,61 @@
+package egmanifest
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"math/rand"
+	"testing"
+
+	"github.com/er-azh/egmanifest/chunks"
+	"github.com/google/uuid"
+)
+
+func TestEncodeChunk(t *testing.T) {
+	random := make([]byte, 4096)
+	rand.New(rand.NewSource(1)).Read(random)
+
+	tests := []struct {
+		name     string
+		data     []byte
+		storedAs chunks.ChunkStoredAs
+	}{
+		{"compressible", bytes.Repeat([]byte("chunk data "), 500), chunks.ChunkStoredAsCompressed},
+		{"random", random, chunks.ChunkStoredAsPlaintext},
+	}
+	for _, test := range tests {
+		guid := uuid.New()
+		raw, err := EncodeChunk(guid, test.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		header, err := chunks.ParseChunkHeader(bytes.NewReader(raw))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		want := chunks.ChunkHeader{
+			Magic:                chunks.ChunkHeaderMagic,
+			Version:              chunkHeaderVersion,
+			HeaderSize:           chunkHeaderSize,
+			DataSizeCompressed:   uint32(len(raw) - chunkHeaderSize),
+			GUID:                 guid,
+			RollingHash:          RollingHash(test.data),
+			StoredAs:             test.storedAs,
+			SHAHash:              sha1.Sum(test.data),
+			HashType:             chunkHashTypes,
+			DataSizeUncompressed: uint32(len(test.data)),
+		}
+		if *header != want {
+			t.Errorf("%s: header = %+v, want %+v", test.name, *header, want)
+		}
+
+		chunk := &Chunk{GUID: guid, SHAHash: sha1.Sum(test.data)}
+		data, err := DecodeChunk(raw, chunk)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !bytes.Equal(data, test.data) {
+			t.Errorf("%s: decoded data doesn't match", test.name)
+		}
+	}
+}
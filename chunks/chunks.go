@@ -34,8 +34,11 @@ type ChunkHeader struct {
 	GUID               uuid.UUID
 	RollingHash        uint64
 	StoredAs           ChunkStoredAs
-	SHAHash            [20]byte
-	HashType           uint32
+	// if Version >= 2
+	SHAHash  [20]byte
+	HashType uint8
+	// if Version >= 3
+	DataSizeUncompressed uint32
 }
 
 func ParseChunkHeader(r io.ReadSeeker) (*ChunkHeader, error) {
@@ -74,15 +77,28 @@ func ParseChunkHeader(r io.ReadSeeker) (*ChunkHeader, error) {
 		return nil, err
 	}
 	header.StoredAs = ChunkStoredAs(storedAs)
-	_, err = io.ReadFull(reader, header.SHAHash[:])
-	if err != nil {
-		return nil, err
+	if header.Version >= 2 {
+		_, err = io.ReadFull(reader, header.SHAHash[:])
+		if err != nil {
+			return nil, err
+		}
+		header.HashType, err = reader.ReadUint8()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if header.Version >= 3 {
+		header.DataSizeUncompressed, err = reader.ReadUint32()
+		if err != nil {
+			return nil, err
+		}
 	}
-	header.HashType, err = reader.ReadUint32()
+	// leave r right after the header for the caller
+	err = reader.Sync()
 	if err != nil {
 		return nil, err
 	}
-	return &header, err
+	return &header, nil
 }
 
 func ParseChunk(reader io.ReadSeeker) (io.ReadSeeker, error) {
@@ -0,0 +1,375 @@
+package egmanifest
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/er-azh/egmanifest/binreader"
+)
+
+// chunkPartDataSize is the serialized size of a chunk part. CompactFileList only stores the
+// DataSize of the parts where it differs.
+const chunkPartDataSize = 28
+
+// compactPart is a chunk part referencing its chunk by index in the chunk data list.
+type compactPart struct {
+	chunk  uint32
+	offset uint32
+	size   uint32
+}
+
+// CompactFileList is a memory efficient alternative to FFileManifestList for very large manifests.
+// Files are stored as a struct of arrays and addressed by index: directory prefixes, install tag
+// sets and symlink targets are interned and chunk parts reference chunks by index.
+// It's read-only, use File to get a *File for the rest of the package.
+type CompactFileList struct {
+	DataSize    uint32
+	DataVersion uint8
+	Count       uint32
+
+	// the chunks that the parts reference, usually ChunkDataList.Chunks
+	chunks []*Chunk
+
+	dirs []string // directory prefixes including the trailing slash, dirs[0] is ""
+	// names holds the base names of all files back to back, nameEnds[i] is where the i-th ends
+	names    string
+	nameEnds []uint32
+	fileDirs []uint32
+
+	symlinks  map[uint32]string
+	shaHashes [][20]byte
+	flags     []EFileMetaFlags
+
+	tags     []string
+	tagSets  [][]uint32 // unique combinations of indexes into tags
+	fileTags []uint32   // index into tagSets
+
+	// parts[partStarts[i]:partStarts[i+1]] are the parts of the i-th file
+	partStarts []uint32
+	parts      []compactPart
+	// DataSize of the parts by index into parts, for the ones that aren't chunkPartDataSize
+	partDataSizes map[uint32]uint32
+}
+
+// Len returns the number of files.
+func (l *CompactFileList) Len() int {
+	return len(l.nameEnds)
+}
+
+// FileName returns the path of the i-th file.
+func (l *CompactFileList) FileName(i int) string {
+	start := uint32(0)
+	if i > 0 {
+		start = l.nameEnds[i-1]
+	}
+	return l.dirs[l.fileDirs[i]] + l.names[start:l.nameEnds[i]]
+}
+
+func (l *CompactFileList) SymlinkTarget(i int) string {
+	return l.symlinks[uint32(i)]
+}
+
+func (l *CompactFileList) SHAHash(i int) [20]byte {
+	return l.shaHashes[i]
+}
+
+func (l *CompactFileList) FileMetaFlags(i int) EFileMetaFlags {
+	return l.flags[i]
+}
+
+// InstallTags returns the install tags of the i-th file.
+func (l *CompactFileList) InstallTags(i int) []string {
+	set := l.tagSets[l.fileTags[i]]
+	if len(set) == 0 {
+		return nil
+	}
+
+	tags := make([]string, len(set))
+	for idx, tag := range set {
+		tags[idx] = l.tags[tag]
+	}
+	return tags
+}
+
+// NumChunkParts returns the number of chunk parts of the i-th file.
+func (l *CompactFileList) NumChunkParts(i int) int {
+	return int(l.partStarts[i+1] - l.partStarts[i])
+}
+
+// ChunkPart returns the part-th chunk part of the i-th file.
+func (l *CompactFileList) ChunkPart(i int, part int) ChunkPart {
+	idx := l.partStarts[i] + uint32(part)
+	p := l.parts[idx]
+	chunk := l.chunks[p.chunk]
+
+	dataSize := uint32(chunkPartDataSize)
+	if size, ok := l.partDataSizes[idx]; ok {
+		dataSize = size
+	}
+	return ChunkPart{
+		DataSize:   dataSize,
+		ParentGUID: chunk.GUID,
+		Offset:     p.offset,
+		Size:       p.size,
+		Chunk:      chunk,
+	}
+}
+
+// Size returns the installed size of the i-th file.
+func (l *CompactFileList) Size(i int) uint64 {
+	var size uint64
+	for _, part := range l.parts[l.partStarts[i]:l.partStarts[i+1]] {
+		size += uint64(part.size)
+	}
+	return size
+}
+
+// File builds the full File for the i-th file.
+func (l *CompactFileList) File(i int) *File {
+	file := &File{
+		FileName:      l.FileName(i),
+		SymlinkTarget: l.SymlinkTarget(i),
+		SHAHash:       l.shaHashes[i],
+		FileMetaFlags: l.flags[i],
+		InstallTags:   l.InstallTags(i),
+		ChunkParts:    make([]ChunkPart, l.NumChunkParts(i)),
+	}
+	for idx := range file.ChunkParts {
+		file.ChunkParts[idx] = l.ChunkPart(i, idx)
+	}
+	return file
+}
+
+// FileManifestList expands the list into a FFileManifestList.
+func (l *CompactFileList) FileManifestList() *FFileManifestList {
+	list := &FFileManifestList{
+		DataSize:         l.DataSize,
+		DataVersion:      l.DataVersion,
+		Count:            l.Count,
+		FileManifestList: make([]File, l.Len()),
+	}
+	for idx := range list.FileManifestList {
+		list.FileManifestList[idx] = *l.File(idx)
+	}
+	return list
+}
+
+// ReadCompactFileList reads a file manifest list section like ReadFileManifestList, into a CompactFileList.
+func ReadCompactFileList(f io.ReadSeeker, dataList *FChunkDataList) (*CompactFileList, error) {
+	reader := binreader.NewReader(f, binary.LittleEndian)
+	list := CompactFileList{
+		chunks: dataList.Chunks,
+		dirs:   []string{""},
+	}
+	var err error
+
+	list.DataSize, err = reader.ReadUint32()
+	if err != nil {
+		return nil, err
+	}
+
+	list.DataVersion, err = reader.ReadUint8()
+	if err != nil {
+		return nil, err
+	}
+
+	list.Count, err = reader.ReadUint32()
+	if err != nil {
+		return nil, err
+	}
+
+	count := int(list.Count)
+	list.nameEnds = make([]uint32, count)
+	list.fileDirs = make([]uint32, count)
+	dirLookup := map[string]uint32{"": 0}
+	var names strings.Builder
+	for idx := 0; idx < count; idx++ {
+		fileName, err := reader.ReadFString()
+		if err != nil {
+			return nil, err
+		}
+
+		split := strings.LastIndexByte(fileName, '/') + 1
+		dir, ok := dirLookup[fileName[:split]]
+		if !ok {
+			dir = uint32(len(list.dirs))
+			dirLookup[fileName[:split]] = dir
+			list.dirs = append(list.dirs, fileName[:split])
+		}
+		list.fileDirs[idx] = dir
+		names.WriteString(fileName[split:])
+		list.nameEnds[idx] = uint32(names.Len())
+	}
+	list.names = names.String()
+
+	for idx := 0; idx < count; idx++ {
+		target, err := reader.ReadFString()
+		if err != nil {
+			return nil, err
+		}
+		if target != "" {
+			if list.symlinks == nil {
+				list.symlinks = map[uint32]string{}
+			}
+			list.symlinks[uint32(idx)] = target
+		}
+	}
+
+	list.shaHashes = make([][20]byte, count)
+	for idx := range list.shaHashes {
+		_, err = io.ReadFull(reader, list.shaHashes[idx][:])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	list.flags = make([]EFileMetaFlags, count)
+	for idx := range list.flags {
+		flags, err := reader.ReadUint8()
+		if err != nil {
+			return nil, err
+		}
+		list.flags[idx] = EFileMetaFlags(flags)
+	}
+
+	list.fileTags = make([]uint32, count)
+	tagLookup := map[string]uint32{}
+	// tag sets are looked up by their tag indexes joined with commas
+	tagSetLookup := map[string]uint32{"": 0}
+	list.tagSets = [][]uint32{nil}
+	var key []byte
+	for idx := 0; idx < count; idx++ {
+		tags, err := reader.ReadFStringArray()
+		if err != nil {
+			return nil, err
+		}
+
+		set := make([]uint32, len(tags))
+		key = key[:0]
+		for tagIdx, tag := range tags {
+			id, ok := tagLookup[tag]
+			if !ok {
+				id = uint32(len(list.tags))
+				tagLookup[tag] = id
+				list.tags = append(list.tags, tag)
+			}
+			set[tagIdx] = id
+			key = strconv.AppendUint(key, uint64(id), 10)
+			key = append(key, ',')
+		}
+
+		setID, ok := tagSetLookup[string(key)]
+		if !ok {
+			setID = uint32(len(list.tagSets))
+			tagSetLookup[string(key)] = setID
+			list.tagSets = append(list.tagSets, set)
+		}
+		list.fileTags[idx] = setID
+	}
+
+	list.partStarts = make([]uint32, count+1)
+	for idx := 0; idx < count; idx++ {
+		chunkPartsSize, err := reader.ReadUint32()
+		if err != nil {
+			return nil, err
+		}
+
+		for cpIdx := uint32(0); cpIdx < chunkPartsSize; cpIdx++ {
+			dataSize, err := reader.ReadUint32()
+			if err != nil {
+				return nil, err
+			}
+			if dataSize != chunkPartDataSize {
+				if list.partDataSizes == nil {
+					list.partDataSizes = map[uint32]uint32{}
+				}
+				list.partDataSizes[uint32(len(list.parts))] = dataSize
+			}
+			parentGUID, err := reader.ReadGUID()
+			if err != nil {
+				return nil, err
+			}
+			chunkID, ok := dataList.ChunkLookup[parentGUID]
+			if !ok {
+				return nil, fmt.Errorf("in chunkPart %d for file %d: parent GUID (%s) not found", cpIdx, idx, parentGUID.String())
+			}
+
+			part := compactPart{chunk: chunkID}
+			part.offset, err = reader.ReadUint32()
+			if err != nil {
+				return nil, err
+			}
+			part.size, err = reader.ReadUint32()
+			if err != nil {
+				return nil, err
+			}
+			list.parts = append(list.parts, part)
+		}
+		list.partStarts[idx+1] = uint32(len(list.parts))
+	}
+	// drop the spare capacity left by append
+	list.parts = append([]compactPart(nil), list.parts...)
+
+	// leave f right after the section for the caller
+	err = reader.Sync()
+	if err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// CompactManifest is a BinaryManifest whose file list is a CompactFileList.
+type CompactManifest struct {
+	Header        *FManifestHeader
+	Metadata      *FManifestMeta
+	ChunkDataList *FChunkDataList
+	Files         *CompactFileList
+	CustomFields  *FCustomFields
+}
+
+// ParseCompactManifest parses a manifest like ParseManifest, keeping the file list in the compact form.
+func ParseCompactManifest(f io.ReadSeeker) (*CompactManifest, error) {
+	var manifest CompactManifest
+	var reader io.ReadSeeker
+	var err error
+	manifest.Header, reader, err = openManifest(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var sections BinaryManifest
+	size, err := remainingSize(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	err = walkSections(reader, size, SectionsAll, &sections, func(r io.ReadSeeker, dataList *FChunkDataList) (uint32, error) {
+		manifest.Files, err = ReadCompactFileList(r, dataList)
+		if err != nil {
+			return 0, err
+		}
+		return manifest.Files.DataSize, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	manifest.Metadata = sections.Metadata
+	manifest.ChunkDataList = sections.ChunkDataList
+	manifest.CustomFields = sections.CustomFields
+	return &manifest, nil
+}
+
+// Manifest expands the manifest into a BinaryManifest sharing the other sections.
+func (m *CompactManifest) Manifest() *BinaryManifest {
+	return &BinaryManifest{
+		Header:           m.Header,
+		Metadata:         m.Metadata,
+		ChunkDataList:    m.ChunkDataList,
+		FileManifestList: m.Files.FileManifestList(),
+		CustomFields:     m.CustomFields,
+	}
+}
@@ -0,0 +1,36 @@
+package egmanifest
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestParseCompactManifest(t *testing.T) {
+	manifest := syntheticManifest(300)
+	files := manifest.FileManifestList.FileManifestList
+	files[3].SymlinkTarget = "Content/Paks/dir0/file_0.paků"
+	files[4].InstallTags = nil
+	// only the compact list's exceptions keep a DataSize that isn't the usual one
+	files[5].ChunkParts[0].DataSize = 32
+	data := encodeTestManifest(manifest, true)
+
+	full, err := ParseManifest(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	compact, err := ParseCompactManifest(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(full, compact.Manifest()) {
+		t.Errorf("expanded compact manifest doesn't match the full one")
+	}
+	if size, want := compact.Files.Size(5), files[5].Size(); size != want {
+		t.Errorf("Size(5) = %d, want %d", size, want)
+	}
+	if part := compact.Files.ChunkPart(5, 0); part.DataSize != 32 {
+		t.Errorf("part DataSize = %d, want 32", part.DataSize)
+	}
+}
@@ -3,15 +3,32 @@ package egmanifest
 import (
 	"encoding/binary"
 	"io"
+	"strings"
 
 	"github.com/er-azh/egmanifest/binreader"
+	"github.com/er-azh/egmanifest/binwriter"
 )
 
 type FCustomFields struct {
 	DataSize    uint32
 	DataVersion uint8
 	Count       uint32
-	Fields      map[string]string
+
+	// the fields in the order they're stored, including duplicate keys
+	Entries []CustomField
+	// map view of Entries, the last entry wins for duplicate keys.
+	// use Set and Delete to change fields, direct changes aren't written by WriteCustomFields
+	Fields map[string]string
+}
+
+type CustomField struct {
+	Key   string
+	Value string
+
+	// whether the key and value were stored as UTF-16, so WriteCustomFields keeps
+	// ASCII strings that were stored as UTF-16 that way
+	KeyUTF16   bool
+	ValueUTF16 bool
 }
 
 func ReadCustomFields(f io.ReadSeeker) (*FCustomFields, error) {
@@ -35,23 +52,166 @@ func ReadCustomFields(f io.ReadSeeker) (*FCustomFields, error) {
 	}
 
 	fields.Fields = map[string]string{}
+	fields.Entries = make([]CustomField, fields.Count)
 
-	// store the keys for the second iteration
-	firstHalf := make([]string, fields.Count)
-	for idx := range firstHalf {
-		firstHalf[idx], err = reader.ReadFString()
+	// all the keys are stored before the values
+	for idx := range fields.Entries {
+		fields.Entries[idx].Key, fields.Entries[idx].KeyUTF16, err = reader.ReadFStringEncoding()
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	// map indexs to keys and use them to build the map
-	for idx := range firstHalf {
-		fields.Fields[firstHalf[idx]], err = reader.ReadFString()
+	for idx := range fields.Entries {
+		fields.Entries[idx].Value, fields.Entries[idx].ValueUTF16, err = reader.ReadFStringEncoding()
 		if err != nil {
 			return nil, err
 		}
+		fields.Fields[fields.Entries[idx].Key] = fields.Entries[idx].Value
 	}
 
+	// leave f right after the section for the caller
+	err = reader.Sync()
+	if err != nil {
+		return nil, err
+	}
 	return &fields, nil
 }
+
+// WriteCustomFields writes fields as a custom fields section, the inverse of ReadCustomFields,
+// and sets fields.DataSize and fields.Count to what was written.
+// Unmodified fields are written byte for byte like they were read, ANSI strings are Latin-1
+// and strings read as UTF-16 stay UTF-16.
+func WriteCustomFields(w io.WriteSeeker, fields *FCustomFields) error {
+	writer := binwriter.NewWriter(w, binary.LittleEndian)
+
+	section, err := writer.BeginSection()
+	if err != nil {
+		return err
+	}
+
+	err = writer.WriteUint8(fields.DataVersion)
+	if err != nil {
+		return err
+	}
+
+	err = writer.WriteUint32(uint32(len(fields.Entries)))
+	if err != nil {
+		return err
+	}
+
+	// strings that were read as UTF-16 are written back that way, even if they're ASCII
+	writeString := func(s string, isUTF16 bool) error {
+		if isUTF16 {
+			return writer.WriteFStringUTF16(s)
+		}
+		return writer.WriteFString(s)
+	}
+
+	for _, field := range fields.Entries {
+		err = writeString(field.Key, field.KeyUTF16)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, field := range fields.Entries {
+		err = writeString(field.Value, field.ValueUTF16)
+		if err != nil {
+			return err
+		}
+	}
+
+	fields.DataSize, err = writer.EndSection(section)
+	if err != nil {
+		return err
+	}
+	fields.Count = uint32(len(fields.Entries))
+	return nil
+}
+
+// Get returns the value of key, the last one if the key is duplicated.
+// A nil *FCustomFields, as left by ParseManifestSections, has no fields.
+func (f *FCustomFields) Get(key string) (string, bool) {
+	if f == nil {
+		return "", false
+	}
+	value, ok := f.Fields[key]
+	return value, ok
+}
+
+// Set sets the value of key, in place for existing keys and at the end for new ones.
+// Every entry of a duplicated key gets the value.
+func (f *FCustomFields) Set(key string, value string) {
+	found := false
+	for idx := range f.Entries {
+		if f.Entries[idx].Key == key {
+			f.Entries[idx].Value = value
+			found = true
+		}
+	}
+	if !found {
+		f.Entries = append(f.Entries, CustomField{Key: key, Value: value})
+	}
+
+	if f.Fields == nil {
+		f.Fields = map[string]string{}
+	}
+	f.Fields[key] = value
+	f.Count = uint32(len(f.Entries))
+}
+
+// Delete removes every entry of key.
+func (f *FCustomFields) Delete(key string) {
+	entries := f.Entries[:0]
+	for _, field := range f.Entries {
+		if field.Key != key {
+			entries = append(entries, field)
+		}
+	}
+	f.Entries = entries
+
+	delete(f.Fields, key)
+	f.Count = uint32(len(f.Entries))
+}
+
+// Duplicates returns the keys that are stored more than once, in the order they first appear.
+// Unreal keeps only the last value, so these usually point at a malformed manifest.
+func (f *FCustomFields) Duplicates() []string {
+	var duplicates []string
+	seen := map[string]int{}
+	for _, field := range f.Entries {
+		seen[field.Key]++
+		if seen[field.Key] == 2 {
+			duplicates = append(duplicates, field.Key)
+		}
+	}
+	return duplicates
+}
+
+// custom fields written by the launcher's build tools
+const (
+	// comma separated list of CloudDir URLs the build is hosted at
+	CustomFieldBaseURL = "BaseUrl"
+	// the label the build was published under, e.g. "Live"
+	CustomFieldBuildLabel = "BuildLabel"
+)
+
+// BaseURLs returns the CloudDir URLs listed in the BaseUrl field, without trailing slashes.
+func (f *FCustomFields) BaseURLs() []string {
+	baseURL, _ := f.Get(CustomFieldBaseURL)
+
+	var urls []string
+	for _, url := range strings.Split(baseURL, ",") {
+		url = strings.TrimRight(strings.TrimSpace(url), "/")
+		if url != "" {
+			urls = append(urls, url)
+		}
+	}
+	return urls
+}
+
+func (f *FCustomFields) BuildLabel() string {
+	label, _ := f.Get(CustomFieldBuildLabel)
+	return label
+}
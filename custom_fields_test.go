@@ -0,0 +1,83 @@
+package egmanifest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/er-azh/egmanifest/binwriter"
+)
+
+func TestCustomFieldsNil(t *testing.T) {
+	var fields *FCustomFields
+	if value, ok := fields.Get(CustomFieldBaseURL); ok || value != "" {
+		t.Errorf("Get on nil fields = %q, %v", value, ok)
+	}
+	if urls := fields.BaseURLs(); urls != nil {
+		t.Errorf("BaseURLs on nil fields = %v", urls)
+	}
+	if label := fields.BuildLabel(); label != "" {
+		t.Errorf("BuildLabel on nil fields = %q", label)
+	}
+
+	manifest, _ := testManifest()
+	data := encodeTestManifest(manifest, true)
+	meta, err := ParseManifestMeta(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dirs := meta.DataDirs(); dirs != nil {
+		t.Errorf("DataDirs without custom fields = %v", dirs)
+	}
+	if _, err := NewManifestMirrorSource(meta); !errors.Is(err, ErrNoMirrors) {
+		t.Errorf("NewManifestMirrorSource without custom fields = %v, want ErrNoMirrors", err)
+	}
+}
+
+func TestCustomFieldsBaseURLs(t *testing.T) {
+	fields := &FCustomFields{}
+	fields.Set(CustomFieldBaseURL, "http://a.example.com/CloudDir/, ,http://b.example.com/CloudDir")
+
+	want := []string{"http://a.example.com/CloudDir", "http://b.example.com/CloudDir"}
+	if urls := fields.BaseURLs(); !reflect.DeepEqual(urls, want) {
+		t.Errorf("BaseURLs = %v, want %v", urls, want)
+	}
+}
+
+func TestCustomFieldsRoundTrip(t *testing.T) {
+	var body bytes.Buffer
+	body.WriteByte(0) // DataVersion
+	body.Write([]byte{2, 0, 0, 0})
+	// keys: "k" as ANSI and "w" as UTF-16
+	body.Write([]byte{2, 0, 0, 0, 'k', 0})
+	body.Write([]byte{0xfe, 0xff, 0xff, 0xff, 'w', 0, 0, 0})
+	// values: "a\xe9" as ANSI and "abc" as UTF-16
+	body.Write([]byte{3, 0, 0, 0, 'a', 0xe9, 0})
+	body.Write([]byte{0xfc, 0xff, 0xff, 0xff, 'a', 0, 'b', 0, 'c', 0, 0, 0})
+
+	section := make([]byte, 4, 4+body.Len())
+	binary.LittleEndian.PutUint32(section, uint32(4+body.Len()))
+	section = append(section, body.Bytes()...)
+
+	fields, err := ReadCustomFields(bytes.NewReader(section))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := fields.Get("k"); value != "a\u00e9" {
+		t.Errorf("ANSI value = %q, want Latin-1 %q", value, "a\u00e9")
+	}
+	if value, _ := fields.Get("w"); value != "abc" {
+		t.Errorf("UTF-16 value = %q, want %q", value, "abc")
+	}
+
+	var buf binwriter.Buffer
+	err = WriteCustomFields(&buf, fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), section) {
+		t.Errorf("written section differs from the one read\n got % x\nwant % x", buf.Bytes(), section)
+	}
+}
@@ -0,0 +1,250 @@
+package egmanifest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrChunkSizeMismatch = errors.New("downloaded chunk size does not match Chunk.FileSize")
+)
+
+type DownloadProgress struct {
+	// the chunk that made progress
+	Chunk *Chunk
+	// bytes of Chunk received by the current attempt
+	ChunkBytes uint64
+
+	// bytes received for all chunks, failed attempts excluded
+	DownloadedBytes uint64
+	// sum of Chunk.FileSize for all chunks
+	TotalBytes uint64
+
+	CompletedChunks int
+	TotalChunks     int
+}
+
+// Downloader fetches chunks concurrently with retries.
+type Downloader struct {
+	Source ChunkSource
+
+	// number of chunks downloaded in parallel
+	Workers int
+	// number of tries per chunk before giving up
+	MaxAttempts int
+	// wait before the first retry, doubled on each following one up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// OnProgress is called from the download workers as data comes in, it must be safe for concurrent use.
+	OnProgress func(DownloadProgress)
+}
+
+func NewDownloader(src ChunkSource) *Downloader {
+	return &Downloader{
+		Source:      src,
+		Workers:     8,
+		MaxAttempts: 4,
+		Backoff:     time.Second,
+		MaxBackoff:  30 * time.Second,
+	}
+}
+
+// downloadState tracks the progress of one Download call.
+type downloadState struct {
+	downloaded  int64
+	completed   int64
+	totalBytes  uint64
+	totalChunks int
+}
+
+// FetchChunk fetches a single chunk with retries, which lets the downloader be used as a ChunkSource.
+func (d *Downloader) FetchChunk(ctx context.Context, c *Chunk) ([]byte, error) {
+	return d.fetch(ctx, c, &downloadState{totalBytes: c.FileSize, totalChunks: 1})
+}
+
+// Download fetches chunks using a pool of workers and passes each raw chunk file to handle.
+// handle is called concurrently from the workers. The first error cancels the remaining downloads.
+func (d *Downloader) Download(ctx context.Context, chunks []*Chunk, handle func(c *Chunk, raw []byte) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// a chunk is only downloaded once even if it's listed several times
+	seen := make(map[uuid.UUID]struct{}, len(chunks))
+	unique := make([]*Chunk, 0, len(chunks))
+	state := &downloadState{}
+	for _, chunk := range chunks {
+		if _, ok := seen[chunk.GUID]; ok {
+			continue
+		}
+		seen[chunk.GUID] = struct{}{}
+		unique = append(unique, chunk)
+		state.totalBytes += chunk.FileSize
+	}
+	state.totalChunks = len(unique)
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	workers := d.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	jobs := make(chan *Chunk)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chunk := range jobs {
+				raw, err := d.fetch(ctx, chunk, state)
+				if err == nil {
+					err = handle(chunk, raw)
+				}
+				if err != nil {
+					fail(err)
+				}
+			}
+		}()
+	}
+
+feed:
+	for _, chunk := range unique {
+		select {
+		case jobs <- chunk:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// fetch downloads c, retrying with backoff until it succeeds or runs out of attempts.
+func (d *Downloader) fetch(ctx context.Context, c *Chunk, state *downloadState) ([]byte, error) {
+	backoff := d.Backoff
+	for attempt := 1; ; attempt++ {
+		raw, err := d.fetchOnce(ctx, c, state)
+		if err == nil {
+			atomic.AddInt64(&state.completed, 1)
+			return raw, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if attempt >= d.MaxAttempts {
+			return nil, fmt.Errorf("chunk %s: giving up after %d attempts: %w", c.GUID, attempt, err)
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+
+		backoff *= 2
+		if d.MaxBackoff > 0 && backoff > d.MaxBackoff {
+			backoff = d.MaxBackoff
+		}
+	}
+}
+
+func (d *Downloader) fetchOnce(ctx context.Context, c *Chunk, state *downloadState) ([]byte, error) {
+	var received uint64
+	report := func(n int) {
+		received += uint64(n)
+		downloaded := atomic.AddInt64(&state.downloaded, int64(n))
+		if d.OnProgress == nil {
+			return
+		}
+		d.OnProgress(DownloadProgress{
+			Chunk:           c,
+			ChunkBytes:      received,
+			DownloadedBytes: uint64(downloaded),
+			TotalBytes:      state.totalBytes,
+			CompletedChunks: int(atomic.LoadInt64(&state.completed)),
+			TotalChunks:     state.totalChunks,
+		})
+	}
+
+	raw, err := d.read(ctx, c, report)
+	if err == nil && c.FileSize != 0 && uint64(len(raw)) != c.FileSize {
+		err = fmt.Errorf("got %d bytes, expected %d: %w", len(raw), c.FileSize, ErrChunkSizeMismatch)
+	}
+	if err != nil {
+		// take the failed attempt out of the totals
+		atomic.AddInt64(&state.downloaded, -int64(received))
+		return nil, err
+	}
+	return raw, nil
+}
+
+// read gets the raw chunk file from the source, streaming it if the source supports it.
+func (d *Downloader) read(ctx context.Context, c *Chunk, report func(n int)) ([]byte, error) {
+	opener, ok := d.Source.(ChunkOpener)
+	if !ok {
+		raw, err := d.Source.FetchChunk(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		report(len(raw))
+		return raw, nil
+	}
+
+	body, size, err := opener.OpenChunk(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var buf bytes.Buffer
+	if size > 0 {
+		buf.Grow(int(size))
+	} else if c.FileSize != 0 {
+		buf.Grow(int(c.FileSize))
+	}
+
+	_, err = io.Copy(&buf, &progressReader{r: body, report: report})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// progressReader reports every read to a callback.
+type progressReader struct {
+	r      io.Reader
+	report func(n int)
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.report(n)
+	}
+	return n, err
+}
@@ -0,0 +1,197 @@
+package egmanifest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var errFlaky = errors.New("flaky source")
+
+// flakySource fails the first failures attempts of every chunk.
+// With partial set, failed attempts stream half of the chunk before failing.
+type flakySource struct {
+	memSource
+	failures int
+	partial  bool
+
+	mu       sync.Mutex
+	attempts map[uuid.UUID]int
+}
+
+func newFlakySource(src memSource, failures int) *flakySource {
+	return &flakySource{memSource: src, failures: failures, attempts: map[uuid.UUID]int{}}
+}
+
+// attempt records an attempt at c and reports whether it should fail.
+func (s *flakySource) attempt(c *Chunk) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attempts[c.GUID]++
+	return s.attempts[c.GUID] <= s.failures
+}
+
+func (s *flakySource) FetchChunk(ctx context.Context, c *Chunk) ([]byte, error) {
+	if s.attempt(c) {
+		return nil, errFlaky
+	}
+	return s.memSource.FetchChunk(ctx, c)
+}
+
+func (s *flakySource) OpenChunk(ctx context.Context, c *Chunk) (io.ReadCloser, int64, error) {
+	raw, err := s.memSource.FetchChunk(ctx, c)
+	if err != nil {
+		return nil, 0, err
+	}
+	var r io.Reader = bytes.NewReader(raw)
+	if s.attempt(c) {
+		if !s.partial {
+			return nil, 0, errFlaky
+		}
+		r = io.MultiReader(bytes.NewReader(raw[:len(raw)/2]), &errReader{errFlaky})
+	}
+	return ioutil.NopCloser(r), int64(len(raw)), nil
+}
+
+type errReader struct {
+	err error
+}
+
+func (r *errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+func testDownloader(src ChunkSource) *Downloader {
+	d := NewDownloader(src)
+	d.Workers = 2
+	d.MaxAttempts = 3
+	d.Backoff = time.Millisecond
+	d.MaxBackoff = 2 * time.Millisecond
+	return d
+}
+
+func TestDownloaderRetries(t *testing.T) {
+	manifest, src := testManifest()
+	chunks := manifest.ChunkDataList.Chunks
+
+	flaky := newFlakySource(src, 2)
+	d := testDownloader(flaky)
+	d.Backoff = 10 * time.Millisecond
+	d.MaxBackoff = 15 * time.Millisecond
+	start := time.Now()
+	err := d.Download(context.Background(), chunks, func(c *Chunk, raw []byte) error {
+		if !bytes.Equal(raw, src[c.GUID]) {
+			t.Errorf("chunk %s: wrong data", c.GUID)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, chunk := range chunks {
+		if attempts := flaky.attempts[chunk.GUID]; attempts != 3 {
+			t.Errorf("chunk %s: %d attempts, want 3", chunk.GUID, attempts)
+		}
+	}
+	// one backoff and a doubled one, capped at MaxBackoff
+	if elapsed := time.Since(start); elapsed < 25*time.Millisecond {
+		t.Errorf("download took %s, shorter than the backoff", elapsed)
+	}
+
+	flaky = newFlakySource(src, 3)
+	_, err = testDownloader(flaky).FetchChunk(context.Background(), chunks[0])
+	if !errors.Is(err, errFlaky) {
+		t.Errorf("FetchChunk = %v, want errFlaky", err)
+	}
+	if attempts := flaky.attempts[chunks[0].GUID]; attempts != 3 {
+		t.Errorf("gave up after %d attempts, want 3", attempts)
+	}
+}
+
+// blockingSource blocks every fetch until its context is done.
+type blockingSource struct {
+	started chan struct{}
+	once    sync.Once
+}
+
+func (s *blockingSource) FetchChunk(ctx context.Context, c *Chunk) ([]byte, error) {
+	s.once.Do(func() {
+		close(s.started)
+	})
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestDownloaderCancel(t *testing.T) {
+	manifest, _ := testManifest()
+	src := &blockingSource{started: make(chan struct{})}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-src.started
+		cancel()
+	}()
+
+	err := testDownloader(src).Download(ctx, manifest.ChunkDataList.Chunks, func(c *Chunk, raw []byte) error {
+		t.Errorf("chunk %s was handled after the download was cancelled", c.GUID)
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Download = %v, want context.Canceled", err)
+	}
+}
+
+func TestDownloaderProgress(t *testing.T) {
+	manifest, src := testManifest()
+	chunks := manifest.ChunkDataList.Chunks
+	var total uint64
+	for _, chunk := range chunks {
+		total += chunk.FileSize
+	}
+
+	for _, partial := range []bool{false, true} {
+		flaky := newFlakySource(src, 1)
+		flaky.partial = partial
+		d := testDownloader(flaky)
+
+		var (
+			mu   sync.Mutex
+			last DownloadProgress
+			peak uint64
+		)
+		d.OnProgress = func(p DownloadProgress) {
+			mu.Lock()
+			defer mu.Unlock()
+			last = p
+			if p.DownloadedBytes > peak {
+				peak = p.DownloadedBytes
+			}
+			if p.DownloadedBytes > p.TotalBytes {
+				t.Errorf("partial %v: downloaded %d of %d bytes", partial, p.DownloadedBytes, p.TotalBytes)
+			}
+		}
+
+		// listing a chunk twice doesn't download it twice
+		listed := append(append([]*Chunk(nil), chunks...), chunks[0])
+		err := d.Download(context.Background(), listed, func(*Chunk, []byte) error {
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if last.TotalBytes != total || last.TotalChunks != len(chunks) {
+			t.Errorf("partial %v: totals %d bytes and %d chunks, want %d and %d", partial, last.TotalBytes, last.TotalChunks, total, len(chunks))
+		}
+		// the bytes of failed attempts are taken out again
+		if peak != total {
+			t.Errorf("partial %v: downloaded %d bytes, want %d", partial, peak, total)
+		}
+	}
+}
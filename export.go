@@ -0,0 +1,138 @@
+package egmanifest
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// exportModTime is used for every archive entry so that exports are reproducible.
+// It's the earliest time zip can store.
+var exportModTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// parentDirs calls add for every parent directory of name that wasn't seen yet, outermost first.
+func parentDirs(name string, seen map[string]struct{}, add func(dir string) error) error {
+	dir := path.Dir(strings.Trim(name, "/"))
+	if dir == "." {
+		return nil
+	}
+	if _, ok := seen[dir]; ok {
+		return nil
+	}
+
+	err := parentDirs(dir, seen, add)
+	if err != nil {
+		return err
+	}
+	seen[dir] = struct{}{}
+	return add(dir)
+}
+
+// ExportTar writes files to w as a tar archive, reading their chunks from src one file at a time.
+// The last decoded chunk is kept across files, so a chunk shared by consecutive files is fetched once.
+// Symlinks are kept and permissions are derived from the meta flags.
+func ExportTar(ctx context.Context, w io.Writer, files []*File, src ChunkSource) error {
+	tw := tar.NewWriter(w)
+	dirs := map[string]struct{}{}
+	cache := &chunkCache{}
+
+	for _, file := range files {
+		err := parentDirs(file.FileName, dirs, func(dir string) error {
+			return tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeDir,
+				Name:     dir + "/",
+				Mode:     0755,
+				ModTime:  exportModTime,
+			})
+		})
+		if err != nil {
+			return err
+		}
+
+		name := strings.TrimPrefix(file.FileName, "/")
+		if file.SymlinkTarget != "" {
+			err = tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeSymlink,
+				Name:     name,
+				Linkname: file.SymlinkTarget,
+				Mode:     0777,
+				ModTime:  exportModTime,
+			})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		err = tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Size:     int64(file.Size()),
+			Mode:     int64(file.FileMetaFlags.FileMode().Perm()),
+			ModTime:  exportModTime,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(tw, newFileReader(ctx, file, src, cache))
+		if err != nil {
+			return err
+		}
+	}
+
+	return tw.Close()
+}
+
+// ExportZip writes files to w as a zip archive, see ExportTar.
+func ExportZip(ctx context.Context, w io.Writer, files []*File, src ChunkSource) error {
+	zw := zip.NewWriter(w)
+	dirs := map[string]struct{}{}
+	cache := &chunkCache{}
+
+	for _, file := range files {
+		err := parentDirs(file.FileName, dirs, func(dir string) error {
+			header := &zip.FileHeader{Name: dir + "/", Modified: exportModTime}
+			header.SetMode(os.ModeDir | 0755)
+			_, err := zw.CreateHeader(header)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		header := &zip.FileHeader{
+			Name:     strings.TrimPrefix(file.FileName, "/"),
+			Method:   zip.Deflate,
+			Modified: exportModTime,
+		}
+		if file.SymlinkTarget != "" {
+			// zip stores symlinks as files holding their target
+			header.Method = zip.Store
+			header.SetMode(os.ModeSymlink | 0777)
+		} else {
+			header.SetMode(file.FileMetaFlags.FileMode())
+		}
+
+		entry, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+
+		if file.SymlinkTarget != "" {
+			_, err = io.WriteString(entry, file.SymlinkTarget)
+		} else {
+			_, err = io.Copy(entry, newFileReader(ctx, file, src, cache))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
@@ -0,0 +1,108 @@
+package egmanifest
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+// exportOrder puts the files of testManifest in an order where consecutive files share chunks.
+func exportOrder(manifest *BinaryManifest) []*File {
+	files := manifest.FileManifestList.Files()
+	return []*File{files[1], files[0], files[2], files[3]}
+}
+
+func TestExportTar(t *testing.T) {
+	manifest, src := testManifest()
+	files := exportOrder(manifest)
+
+	counter := &countingSource{memSource: src}
+	var buf bytes.Buffer
+	err := ExportTar(context.Background(), &buf, files, counter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counter.fetched != 2 {
+		t.Errorf("fetched %d chunks, want 2", counter.fetched)
+	}
+
+	byName := manifest.FileManifestList.Index()
+	tr := tar.NewReader(&buf)
+	var found int
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		file, ok := byName.Lookup(header.Name)
+		if !ok {
+			continue
+		}
+		found++
+		if file.SymlinkTarget != "" {
+			if header.Typeflag != tar.TypeSymlink || header.Linkname != file.SymlinkTarget {
+				t.Errorf("%s: got %c -> %q", header.Name, header.Typeflag, header.Linkname)
+			}
+			continue
+		}
+
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sha1.Sum(data) != file.SHAHash {
+			t.Errorf("%s: content doesn't match its hash", header.Name)
+		}
+	}
+	if found != len(files) {
+		t.Errorf("found %d of %d files", found, len(files))
+	}
+}
+
+func TestExportZip(t *testing.T) {
+	manifest, src := testManifest()
+	files := exportOrder(manifest)
+
+	counter := &countingSource{memSource: src}
+	var buf bytes.Buffer
+	err := ExportZip(context.Background(), &buf, files, counter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counter.fetched != 2 {
+		t.Errorf("fetched %d chunks, want 2", counter.fetched)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	byName := manifest.FileManifestList.Index()
+	for _, entry := range zr.File {
+		file, ok := byName.Lookup(entry.Name)
+		if !ok || file.SymlinkTarget != "" {
+			continue
+		}
+
+		fh, err := entry.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadAll(fh)
+		fh.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sha1.Sum(data) != file.SHAHash {
+			t.Errorf("%s: content doesn't match its hash", entry.Name)
+		}
+	}
+}
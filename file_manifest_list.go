@@ -6,6 +6,7 @@ import (
 	"io"
 
 	"github.com/er-azh/egmanifest/binreader"
+	"github.com/er-azh/egmanifest/binwriter"
 	"github.com/google/uuid"
 )
 
@@ -26,17 +27,34 @@ type ChunkPart struct {
 	Chunk *Chunk
 }
 
-//TODO: implement io.ReadSeeker on this
 type File struct {
 	FileName      string
 	SymlinkTarget string
 	SHAHash       [20]byte
-	FileMetaFlags uint8
+	FileMetaFlags EFileMetaFlags
 	InstallTags   []string
 
 	ChunkParts []ChunkPart
 }
 
+// Files returns pointers to all the files of the list.
+func (l *FFileManifestList) Files() []*File {
+	files := make([]*File, len(l.FileManifestList))
+	for idx := range l.FileManifestList {
+		files[idx] = &l.FileManifestList[idx]
+	}
+	return files
+}
+
+// Size returns the installed size of the file.
+func (f *File) Size() uint64 {
+	var size uint64
+	for _, part := range f.ChunkParts {
+		size += uint64(part.Size)
+	}
+	return size
+}
+
 func ReadFileManifestList(f io.ReadSeeker, dataList *FChunkDataList) (*FFileManifestList, error) {
 	reader := binreader.NewReader(f, binary.LittleEndian)
 	var list FFileManifestList
@@ -74,18 +92,18 @@ func ReadFileManifestList(f io.ReadSeeker, dataList *FChunkDataList) (*FFileMani
 	}
 
 	for idx := range list.FileManifestList {
-		_, shaHash, err := reader.ReadBytes(20)
+		_, err = io.ReadFull(reader, list.FileManifestList[idx].SHAHash[:])
 		if err != nil {
 			return nil, err
 		}
-		copy(list.FileManifestList[idx].SHAHash[:], shaHash)
 	}
 
 	for idx := range list.FileManifestList {
-		list.FileManifestList[idx].FileMetaFlags, err = reader.ReadUint8()
+		flags, err := reader.ReadUint8()
 		if err != nil {
 			return nil, err
 		}
+		list.FileManifestList[idx].FileMetaFlags = EFileMetaFlags(flags)
 	}
 
 	for idx := range list.FileManifestList {
@@ -129,5 +147,62 @@ func ReadFileManifestList(f io.ReadSeeker, dataList *FChunkDataList) (*FFileMani
 		}
 
 	}
+	// leave f right after the section for the caller
+	err = reader.Sync()
+	if err != nil {
+		return nil, err
+	}
 	return &list, nil
 }
+
+// WriteFileManifestList writes list as a file manifest list section, the inverse of ReadFileManifestList,
+// and sets list.DataSize and list.Count to what was written. Chunk parts without a DataSize get the usual one.
+func WriteFileManifestList(w io.Writer, list *FFileManifestList) error {
+	// writes to a Buffer can't fail
+	var buf binwriter.Buffer
+	writer := binwriter.NewWriter(&buf, binary.LittleEndian)
+	files := list.FileManifestList
+
+	section, _ := writer.BeginSection()
+	writer.WriteUint8(list.DataVersion)
+	writer.WriteUint32(uint32(len(files)))
+	for idx := range files {
+		writer.WriteFString(files[idx].FileName)
+	}
+	for idx := range files {
+		writer.WriteFString(files[idx].SymlinkTarget)
+	}
+	for idx := range files {
+		writer.WriteBytes(files[idx].SHAHash[:])
+	}
+	for idx := range files {
+		writer.WriteUint8(uint8(files[idx].FileMetaFlags))
+	}
+	for idx := range files {
+		writer.WriteFStringArray(files[idx].InstallTags)
+	}
+	for idx := range files {
+		parts := files[idx].ChunkParts
+		writer.WriteUint32(uint32(len(parts)))
+		for cpIdx := range parts {
+			part := &parts[cpIdx]
+			if part.DataSize == 0 {
+				part.DataSize = chunkPartDataSize
+			}
+			writer.WriteUint32(part.DataSize)
+			writer.WriteGUID(part.ParentGUID)
+			writer.WriteUint32(part.Offset)
+			writer.WriteUint32(part.Size)
+		}
+	}
+
+	size, err := writer.EndSection(section)
+	if err != nil {
+		return err
+	}
+	list.DataSize = size
+	list.Count = uint32(len(files))
+
+	_, err = w.Write(buf.Bytes())
+	return err
+}
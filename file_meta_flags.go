@@ -0,0 +1,77 @@
+package egmanifest
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// EFileMetaFlags are the attributes BuildPatchServices stores for each file of a build.
+type EFileMetaFlags uint8
+
+const (
+	EFileMetaFlagsNone EFileMetaFlags = 0
+	// Flag for readonly file.
+	EFileMetaFlagsReadOnly EFileMetaFlags = 1 << 0
+	// Flag for natively compressed.
+	EFileMetaFlagsCompressed EFileMetaFlags = 1 << 1
+	// Flag for unix executable.
+	EFileMetaFlagsUnixExecutable EFileMetaFlags = 1 << 2
+)
+
+func (f EFileMetaFlags) IsReadOnly() bool {
+	return f&EFileMetaFlagsReadOnly != 0
+}
+
+func (f EFileMetaFlags) IsCompressed() bool {
+	return f&EFileMetaFlagsCompressed != 0
+}
+
+func (f EFileMetaFlags) IsExecutable() bool {
+	return f&EFileMetaFlagsUnixExecutable != 0
+}
+
+func (f EFileMetaFlags) String() string {
+	if f == EFileMetaFlagsNone {
+		return "None"
+	}
+
+	var names []string
+	if f.IsReadOnly() {
+		names = append(names, "ReadOnly")
+	}
+	if f.IsCompressed() {
+		names = append(names, "Compressed")
+	}
+	if f.IsExecutable() {
+		names = append(names, "UnixExecutable")
+	}
+	if unknown := f &^ (EFileMetaFlagsReadOnly | EFileMetaFlagsCompressed | EFileMetaFlagsUnixExecutable); unknown != 0 {
+		names = append(names, fmt.Sprintf("0x%02X", uint8(unknown)))
+	}
+	return strings.Join(names, "|")
+}
+
+// FileMode returns the permissions of an installed file with these flags.
+func (f EFileMetaFlags) FileMode() os.FileMode {
+	mode := os.FileMode(0644)
+	if f.IsExecutable() {
+		mode = 0755
+	}
+	if f.IsReadOnly() {
+		mode &^= 0222
+	}
+	return mode
+}
+
+// FileMetaFlagsFromMode returns the flags matching the permissions of a file.
+func FileMetaFlagsFromMode(mode os.FileMode) EFileMetaFlags {
+	var flags EFileMetaFlags
+	if mode&0111 != 0 {
+		flags |= EFileMetaFlagsUnixExecutable
+	}
+	if mode&0222 == 0 {
+		flags |= EFileMetaFlagsReadOnly
+	}
+	return flags
+}
@@ -0,0 +1,143 @@
+package egmanifest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrNegativeOffset = errors.New("negative offset")
+)
+
+// FileReader reads the contents of a File from its chunks, fetching them as they're needed.
+// It implements io.ReadSeeker and io.ReaderAt.
+type FileReader struct {
+	ctx  context.Context
+	file *File
+	src  ChunkSource
+
+	// offset of each chunk part in the file
+	offsets []int64
+	size    int64
+	pos     int64
+
+	cache *chunkCache
+}
+
+// chunkCache keeps the last decoded chunk. Parts of a file tend to come from the same chunk,
+// and so do small files next to each other, so readers of consecutive files can share one.
+type chunkCache struct {
+	mu    sync.Mutex
+	chunk *Chunk
+	data  []byte
+}
+
+func (cc *chunkCache) get(ctx context.Context, src ChunkSource, c *Chunk) ([]byte, error) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	if cc.chunk == c {
+		return cc.data, nil
+	}
+
+	data, err := ReadChunkData(ctx, src, c)
+	if err != nil {
+		return nil, err
+	}
+	cc.chunk, cc.data = c, data
+	return data, nil
+}
+
+func NewFileReader(ctx context.Context, file *File, src ChunkSource) *FileReader {
+	return newFileReader(ctx, file, src, &chunkCache{})
+}
+
+// newFileReader returns a FileReader that shares cache with other readers.
+func newFileReader(ctx context.Context, file *File, src ChunkSource, cache *chunkCache) *FileReader {
+	r := &FileReader{
+		ctx:     ctx,
+		file:    file,
+		src:     src,
+		offsets: make([]int64, len(file.ChunkParts)),
+		cache:   cache,
+	}
+	for idx, part := range file.ChunkParts {
+		r.offsets[idx] = r.size
+		r.size += int64(part.Size)
+	}
+	return r
+}
+
+// Size returns the size of the file.
+func (r *FileReader) Size() int64 {
+	return r.size
+}
+
+func (r *FileReader) Read(p []byte) (int, error) {
+	n, err := r.ReadAt(p, r.pos)
+	r.pos += int64(n)
+	if err == io.EOF && n != 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (r *FileReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.pos
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, fmt.Errorf("invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, ErrNegativeOffset
+	}
+	r.pos = offset
+	return offset, nil
+}
+
+func (r *FileReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, ErrNegativeOffset
+	}
+
+	var n int
+	for n < len(p) {
+		if off >= r.size {
+			return n, io.EOF
+		}
+
+		// the last part starting at or before off
+		idx := sort.Search(len(r.offsets), func(i int) bool {
+			return r.offsets[i] > off
+		}) - 1
+		part := &r.file.ChunkParts[idx]
+
+		data, err := r.chunkData(part.Chunk)
+		if err != nil {
+			return n, err
+		}
+
+		start := uint64(part.Offset) + uint64(off-r.offsets[idx])
+		end := uint64(part.Offset) + uint64(part.Size)
+		if end > uint64(len(data)) {
+			return n, fmt.Errorf("chunk part of %s is out of range of chunk %s", r.file.FileName, part.ParentGUID)
+		}
+
+		copied := copy(p[n:], data[start:end])
+		n += copied
+		off += int64(copied)
+	}
+	return n, nil
+}
+
+func (r *FileReader) chunkData(c *Chunk) ([]byte, error) {
+	return r.cache.get(r.ctx, r.src, c)
+}
@@ -0,0 +1,291 @@
+package egmanifest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ManifestFS is a read-only fs.FS over the files of a manifest.
+// Directories are derived from the file names and file contents are streamed from chunks on demand.
+// Symlinks are followed when they point inside the build, absolute targets never do.
+type ManifestFS struct {
+	ctx  context.Context
+	src  ChunkSource
+	root *fsNode
+}
+
+var (
+	_ fs.ReadDirFS = (*ManifestFS)(nil)
+	_ fs.StatFS    = (*ManifestFS)(nil)
+)
+
+// fsNode is a file or a directory of the tree.
+type fsNode struct {
+	name     string
+	file     *File // nil for directories
+	children map[string]*fsNode
+	sorted   []*fsNode
+}
+
+// NewManifestFS builds the directory tree of list. ctx is used for fetching chunks.
+// Names with empty, "." or ".." elements and files that are also used as a directory are an error.
+// When several files have the same name the first one wins, like in PathIndex.
+func NewManifestFS(ctx context.Context, list *FFileManifestList, src ChunkSource) (*ManifestFS, error) {
+	fsys := &ManifestFS{
+		ctx:  ctx,
+		src:  src,
+		root: &fsNode{name: ".", children: map[string]*fsNode{}},
+	}
+
+	for idx := range list.FileManifestList {
+		file := &list.FileManifestList[idx]
+		name := strings.TrimPrefix(file.FileName, "/")
+		if !fs.ValidPath(name) || name == "." {
+			return nil, fmt.Errorf("file name %q: %w", file.FileName, fs.ErrInvalid)
+		}
+		parts := strings.Split(name, "/")
+
+		dir := fsys.root
+		for depth, elem := range parts[:len(parts)-1] {
+			child, ok := dir.children[elem]
+			if !ok {
+				child = &fsNode{name: elem, children: map[string]*fsNode{}}
+				dir.children[elem] = child
+			} else if child.file != nil {
+				return nil, fmt.Errorf("%q is a file and the directory of %q: %w", path.Join(parts[:depth+1]...), file.FileName, fs.ErrExist)
+			}
+			dir = child
+		}
+
+		base := parts[len(parts)-1]
+		if existing, ok := dir.children[base]; ok {
+			if existing.file == nil {
+				return nil, fmt.Errorf("%q is a file and a directory: %w", file.FileName, fs.ErrExist)
+			}
+			continue
+		}
+		dir.children[base] = &fsNode{name: base, file: file}
+	}
+
+	fsys.root.sort()
+	return fsys, nil
+}
+
+func (n *fsNode) sort() {
+	n.sorted = make([]*fsNode, 0, len(n.children))
+	for _, child := range n.children {
+		n.sorted = append(n.sorted, child)
+		if child.file == nil {
+			child.sort()
+		}
+	}
+	sort.Slice(n.sorted, func(i, j int) bool {
+		return n.sorted[i].name < n.sorted[j].name
+	})
+}
+
+// maxSymlinks is how many symlinks are followed while resolving a path.
+const maxSymlinks = 40
+
+// lookup finds the node of name, following symlinks in the directories of name
+// and, if follow is set, in name itself.
+func (fsys *ManifestFS) lookup(op string, name string, follow bool) (*fsNode, string, error) {
+	if !fs.ValidPath(name) {
+		return nil, "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	orig := name
+	hops := 0
+walk:
+	for {
+		node := fsys.root
+		if name == "." {
+			return node, name, nil
+		}
+
+		elems := strings.Split(name, "/")
+		for idx, elem := range elems {
+			if node.file != nil {
+				return nil, "", &fs.PathError{Op: op, Path: orig, Err: fs.ErrNotExist}
+			}
+			child, ok := node.children[elem]
+			if !ok {
+				return nil, "", &fs.PathError{Op: op, Path: orig, Err: fs.ErrNotExist}
+			}
+			node = child
+
+			last := idx == len(elems)-1
+			if node.file == nil || node.file.SymlinkTarget == "" || last && !follow {
+				continue
+			}
+
+			hops++
+			if hops > maxSymlinks {
+				return nil, "", &fs.PathError{Op: op, Path: orig, Err: errors.New("too many levels of symbolic links")}
+			}
+			target := node.file.SymlinkTarget
+			if strings.HasPrefix(target, "/") {
+				// absolute targets point at the installing system, not into the build
+				return nil, "", &fs.PathError{Op: op, Path: orig, Err: fs.ErrNotExist}
+			}
+			target = path.Join(path.Join(elems[:idx]...), target)
+			name = path.Join(append([]string{target}, elems[idx+1:]...)...)
+			if name == "" {
+				name = "."
+			}
+			if !fs.ValidPath(name) {
+				// points outside of the build
+				return nil, "", &fs.PathError{Op: op, Path: orig, Err: fs.ErrNotExist}
+			}
+			continue walk
+		}
+		return node, name, nil
+	}
+}
+
+func (fsys *ManifestFS) Open(name string) (fs.File, error) {
+	node, resolved, err := fsys.lookup("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &fsFileInfo{name: path.Base(name), node: node}
+	if node.file == nil {
+		return &fsDir{info: info, path: resolved}, nil
+	}
+	return &fsFile{info: info, FileReader: NewFileReader(fsys.ctx, node.file, fsys.src)}, nil
+}
+
+func (fsys *ManifestFS) Stat(name string) (fs.FileInfo, error) {
+	node, _, err := fsys.lookup("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return &fsFileInfo{name: path.Base(name), node: node}, nil
+}
+
+func (fsys *ManifestFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	node, _, err := fsys.lookup("readdir", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if node.file != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+
+	entries := make([]fs.DirEntry, len(node.sorted))
+	for idx, child := range node.sorted {
+		entries[idx] = &fsFileInfo{name: child.name, node: child}
+	}
+	return entries, nil
+}
+
+// fsFileInfo implements both fs.FileInfo and fs.DirEntry.
+type fsFileInfo struct {
+	// the name the node was looked up with, which differs from the node's for symlinks
+	name string
+	node *fsNode
+}
+
+func (i *fsFileInfo) Name() string {
+	return i.name
+}
+
+func (i *fsFileInfo) Size() int64 {
+	if i.node.file == nil {
+		return 0
+	}
+	if i.node.file.SymlinkTarget != "" {
+		return int64(len(i.node.file.SymlinkTarget))
+	}
+	return int64(i.node.file.Size())
+}
+
+func (i *fsFileInfo) Mode() fs.FileMode {
+	switch {
+	case i.node.file == nil:
+		return fs.ModeDir | 0755
+	case i.node.file.SymlinkTarget != "":
+		return fs.ModeSymlink | 0777
+	}
+	return i.node.file.FileMetaFlags.FileMode()
+}
+
+func (i *fsFileInfo) ModTime() time.Time {
+	return time.Time{}
+}
+
+func (i *fsFileInfo) IsDir() bool {
+	return i.node.file == nil
+}
+
+// Sys returns the *File of the manifest, nil for directories.
+func (i *fsFileInfo) Sys() interface{} {
+	if i.node.file == nil {
+		return nil
+	}
+	return i.node.file
+}
+
+func (i *fsFileInfo) Type() fs.FileMode {
+	return i.Mode().Type()
+}
+
+func (i *fsFileInfo) Info() (fs.FileInfo, error) {
+	return i, nil
+}
+
+type fsFile struct {
+	info *fsFileInfo
+	*FileReader
+}
+
+func (f *fsFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *fsFile) Close() error {
+	return nil
+}
+
+type fsDir struct {
+	info   *fsFileInfo
+	path   string
+	offset int
+}
+
+func (d *fsDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *fsDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.path, Err: errors.New("is a directory")}
+}
+
+func (d *fsDir) Close() error {
+	return nil
+}
+
+func (d *fsDir) ReadDir(count int) ([]fs.DirEntry, error) {
+	children := d.info.node.sorted[d.offset:]
+	if count > 0 && len(children) == 0 {
+		return nil, io.EOF
+	}
+	if count > 0 && count < len(children) {
+		children = children[:count]
+	}
+
+	entries := make([]fs.DirEntry, len(children))
+	for idx, child := range children {
+		entries[idx] = &fsFileInfo{name: child.name, node: child}
+	}
+	d.offset += len(children)
+	return entries, nil
+}
@@ -0,0 +1,89 @@
+package egmanifest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestManifestFS(t *testing.T) {
+	manifest, src := testManifest()
+	fsys, err := NewManifestFS(context.Background(), manifest.FileManifestList, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fstest.TestFS(fsys, "bin/game.exe", "data/a.pak", "data/b.pak", "link")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exe, err := fs.ReadFile(fsys, "bin/game.exe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	link, err := fs.ReadFile(fsys, "link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(exe, link) {
+		t.Errorf("reading the symlink doesn't return its target's content")
+	}
+}
+
+func TestManifestFSAbsoluteSymlink(t *testing.T) {
+	manifest, src := testManifest()
+	files := manifest.FileManifestList.FileManifestList
+	// the build has bin/game.exe, an absolute link must not resolve to it
+	files[3].SymlinkTarget = "/bin/game.exe"
+	fsys, err := NewManifestFS(context.Background(), manifest.FileManifestList, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = fs.ReadFile(fsys, "link")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("reading an absolute symlink = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestManifestFSConflicts(t *testing.T) {
+	for _, names := range [][]string{{"a", "a/b"}, {"a/b", "a"}, {"a/b/c", "a/b"}} {
+		list := &FFileManifestList{}
+		for _, name := range names {
+			list.FileManifestList = append(list.FileManifestList, File{FileName: name})
+		}
+
+		_, err := NewManifestFS(context.Background(), list, memSource{})
+		if !errors.Is(err, fs.ErrExist) {
+			t.Errorf("%v: got %v, want fs.ErrExist", names, err)
+		}
+	}
+}
+
+func TestManifestFSInvalidNames(t *testing.T) {
+	for _, name := range []string{"", "a//b", "a/./b", "../a", "a/..", "a/"} {
+		list := &FFileManifestList{FileManifestList: []File{{FileName: name}}}
+		_, err := NewManifestFS(context.Background(), list, memSource{})
+		if !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("%q: got %v, want fs.ErrInvalid", name, err)
+		}
+	}
+
+	// duplicates keep the first file
+	list := &FFileManifestList{FileManifestList: []File{{FileName: "a", SymlinkTarget: "x"}, {FileName: "a"}}}
+	fsys, err := NewManifestFS(context.Background(), list, memSource{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := fsys.ReadDir(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Type()&fs.ModeSymlink == 0 {
+		t.Errorf("duplicate name: got %v, want only the first file, a symlink", entries)
+	}
+}
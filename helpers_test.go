@@ -0,0 +1,108 @@
+package egmanifest
+
+import (
+	"context"
+	"crypto/sha1"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+// memSource serves chunk files from memory.
+type memSource map[uuid.UUID][]byte
+
+func (s memSource) FetchChunk(ctx context.Context, c *Chunk) ([]byte, error) {
+	data, ok := s[c.GUID]
+	if !ok {
+		return nil, ErrChunkNotFound
+	}
+	return data, nil
+}
+
+// errFetchLimit is returned by a countingSource once its limit is reached.
+var errFetchLimit = errors.New("fetch limit reached")
+
+// countingSource counts the chunks fetched from a memSource.
+// If limit is set, fetches fail after that many chunks, like an interrupted download.
+type countingSource struct {
+	memSource
+	fetched int
+	limit   int
+}
+
+func (s *countingSource) FetchChunk(ctx context.Context, c *Chunk) ([]byte, error) {
+	if s.limit > 0 && s.fetched >= s.limit {
+		return nil, errFetchLimit
+	}
+	s.fetched++
+	return s.memSource.FetchChunk(ctx, c)
+}
+
+// testManifest builds a manifest of three files made from two chunks and a symlink,
+// and a source holding the chunks.
+func testManifest() (*BinaryManifest, memSource) {
+	src := memSource{}
+	dataList := &FChunkDataList{ChunkLookup: map[uuid.UUID]uint32{}}
+	var contents [][]byte
+	for i := 0; i < 2; i++ {
+		data := make([]byte, 1000)
+		for j := range data {
+			data[j] = byte('a'+i) + byte(j%3)
+		}
+
+		guid := uuid.New()
+		raw, _ := EncodeChunk(guid, data)
+		src[guid] = raw
+		dataList.ChunkLookup[guid] = uint32(len(dataList.Chunks))
+		dataList.Chunks = append(dataList.Chunks, &Chunk{
+			GUID:       guid,
+			Hash:       uint64(i + 1),
+			SHAHash:    sha1.Sum(data),
+			Group:      uint8(i),
+			WindowSize: uint32(len(data)),
+			FileSize:   uint64(len(raw)),
+		})
+		contents = append(contents, data)
+	}
+	dataList.Count = uint32(len(dataList.Chunks))
+
+	mkFile := func(name string, parts ...ChunkPart) File {
+		var content []byte
+		for idx := range parts {
+			part := &parts[idx]
+			part.ParentGUID = part.Chunk.GUID
+			data := contents[dataList.ChunkLookup[part.ParentGUID]]
+			content = append(content, data[part.Offset:part.Offset+part.Size]...)
+		}
+		return File{FileName: name, SHAHash: sha1.Sum(content), ChunkParts: parts}
+	}
+
+	c0, c1 := dataList.Chunks[0], dataList.Chunks[1]
+	files := []File{
+		mkFile("bin/game.exe", ChunkPart{Chunk: c0, Offset: 0, Size: 600}, ChunkPart{Chunk: c1, Offset: 100, Size: 300}),
+		mkFile("data/a.pak", ChunkPart{Chunk: c0, Offset: 600, Size: 400}),
+		mkFile("data/b.pak", ChunkPart{Chunk: c1, Offset: 500, Size: 500}),
+		{FileName: "link", SymlinkTarget: "bin/game.exe"},
+	}
+	files[0].FileMetaFlags = 4
+	files[1].InstallTags = []string{"hd"}
+	files[2].InstallTags = []string{"fr"}
+
+	fields := &FCustomFields{}
+	fields.Set(CustomFieldBuildLabel, "Live")
+
+	manifest := &BinaryManifest{
+		Header:           &FManifestHeader{Version: EFeatureLevelStoresUniqueBuildId},
+		Metadata:         &FManifestMeta{FeatureLevel: EFeatureLevelStoresUniqueBuildId, AppName: "Test", BuildVersion: "1.0"},
+		ChunkDataList:    dataList,
+		FileManifestList: &FFileManifestList{Count: uint32(len(files)), FileManifestList: files},
+		CustomFields:     fields,
+	}
+	return manifest, src
+}
+
+// Put stores raw, so a memSource can be the ChunkWriter of an import.
+func (s memSource) Put(c *Chunk, raw []byte) error {
+	s[c.GUID] = append([]byte(nil), raw...)
+	return nil
+}
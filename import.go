@@ -0,0 +1,298 @@
+package egmanifest
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+type ImportOptions struct {
+	AppName       string
+	BuildVersion  string
+	LaunchExe     string
+	LaunchCommand string
+	// size of the chunks, defaults to DefaultWindowSize
+	WindowSize uint32
+}
+
+// importer packs the files of an archive into chunks.
+type importer struct {
+	out        ChunkWriter
+	windowSize int
+
+	files  []*File
+	byName map[string]*File
+
+	chunkList *FChunkDataList
+	// chunks by the SHA1 of their data, identical chunks are only stored once
+	bySHA map[[20]byte]*Chunk
+
+	// the chunk being filled and the parts that point into it
+	current *Chunk
+	buf     []byte
+	pending []*ChunkPart
+}
+
+func newImporter(out ChunkWriter, windowSize uint32) *importer {
+	if windowSize == 0 {
+		windowSize = DefaultWindowSize
+	}
+	return &importer{
+		out:        out,
+		windowSize: int(windowSize),
+		byName:     map[string]*File{},
+		chunkList:  &FChunkDataList{ChunkLookup: map[uuid.UUID]uint32{}},
+		bySHA:      map[[20]byte]*Chunk{},
+	}
+}
+
+func cleanArchiveName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+func (im *importer) addSymlink(name string, target string) {
+	file := &File{FileName: cleanArchiveName(name), SymlinkTarget: target}
+	im.files = append(im.files, file)
+	im.byName[file.FileName] = file
+}
+
+// addHardlink adds a file with the same contents as an already imported one.
+func (im *importer) addHardlink(name string, target string, mode os.FileMode) error {
+	original, ok := im.byName[cleanArchiveName(target)]
+	if !ok {
+		return fmt.Errorf("hard link %s points to unknown file %s", name, target)
+	}
+
+	file := &File{
+		FileName:      cleanArchiveName(name),
+		SymlinkTarget: original.SymlinkTarget,
+		SHAHash:       original.SHAHash,
+		FileMetaFlags: FileMetaFlagsFromMode(mode),
+		ChunkParts:    make([]ChunkPart, len(original.ChunkParts)),
+	}
+	copy(file.ChunkParts, original.ChunkParts)
+	im.files = append(im.files, file)
+	im.byName[file.FileName] = file
+
+	// parts of the chunk that isn't written yet get fixed up when it is
+	for idx := range file.ChunkParts {
+		if file.ChunkParts[idx].Chunk == im.current {
+			im.pending = append(im.pending, &file.ChunkParts[idx])
+		}
+	}
+	return nil
+}
+
+func (im *importer) addFile(name string, mode os.FileMode, r io.Reader) error {
+	file := &File{
+		FileName:      cleanArchiveName(name),
+		FileMetaFlags: FileMetaFlagsFromMode(mode),
+	}
+	hasher := sha1.New()
+
+	for {
+		if im.current == nil {
+			im.current = &Chunk{GUID: uuid.New()}
+			im.buf = make([]byte, 0, im.windowSize)
+		}
+
+		start := len(im.buf)
+		n, err := io.ReadFull(r, im.buf[start:im.windowSize])
+		if n > 0 {
+			im.buf = im.buf[:start+n]
+			hasher.Write(im.buf[start:])
+			file.ChunkParts = append(file.ChunkParts, ChunkPart{
+				ParentGUID: im.current.GUID,
+				Offset:     uint32(start),
+				Size:       uint32(n),
+				Chunk:      im.current,
+			})
+		}
+
+		if len(im.buf) == im.windowSize {
+			// the parts are only referenced once the file slice stops growing
+			err := im.flush(file)
+			if err != nil {
+				return err
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		} else if err != nil {
+			return err
+		}
+	}
+
+	copy(file.SHAHash[:], hasher.Sum(nil))
+	for idx := range file.ChunkParts {
+		if file.ChunkParts[idx].Chunk == im.current {
+			im.pending = append(im.pending, &file.ChunkParts[idx])
+		}
+	}
+	im.files = append(im.files, file)
+	im.byName[file.FileName] = file
+	return nil
+}
+
+// flush writes the current chunk. file is the file being added, whose parts aren't in pending yet.
+func (im *importer) flush(file *File) error {
+	if im.current == nil {
+		return nil
+	}
+	chunk, data := im.current, im.buf
+	im.current, im.buf = nil, nil
+
+	parts := im.pending
+	im.pending = nil
+	if file != nil {
+		for idx := range file.ChunkParts {
+			if file.ChunkParts[idx].Chunk == chunk {
+				parts = append(parts, &file.ChunkParts[idx])
+			}
+		}
+	}
+
+	shaHash := sha1.Sum(data)
+	if existing, ok := im.bySHA[shaHash]; ok {
+		for _, part := range parts {
+			part.Chunk = existing
+			part.ParentGUID = existing.GUID
+		}
+		return nil
+	}
+
+	raw, err := EncodeChunk(chunk.GUID, data)
+	if err != nil {
+		return err
+	}
+
+	chunk.Hash = RollingHash(data)
+	chunk.SHAHash = shaHash
+	chunk.Group = ChunkGroup(chunk.GUID)
+	chunk.WindowSize = uint32(len(data))
+	chunk.FileSize = uint64(len(raw))
+
+	err = im.out.Put(chunk, raw)
+	if err != nil {
+		return err
+	}
+
+	im.bySHA[shaHash] = chunk
+	im.chunkList.ChunkLookup[chunk.GUID] = uint32(len(im.chunkList.Chunks))
+	im.chunkList.Chunks = append(im.chunkList.Chunks, chunk)
+	return nil
+}
+
+// finish writes the last chunk and builds the manifest.
+func (im *importer) finish(opts ImportOptions) (*BinaryManifest, error) {
+	if len(im.buf) != 0 {
+		err := im.flush(nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	im.chunkList.Count = uint32(len(im.chunkList.Chunks))
+
+	fileList := &FFileManifestList{
+		Count:            uint32(len(im.files)),
+		FileManifestList: make([]File, len(im.files)),
+	}
+	for idx, file := range im.files {
+		fileList.FileManifestList[idx] = *file
+	}
+
+	id := uuid.New()
+	return &BinaryManifest{
+		Header: &FManifestHeader{
+			StoredAs: StoredCompressed,
+			Version:  EFeatureLevelLatest,
+		},
+		Metadata: &FManifestMeta{
+			DataVersion:   1,
+			FeatureLevel:  EFeatureLevelLatest,
+			AppName:       opts.AppName,
+			BuildVersion:  opts.BuildVersion,
+			LaunchExe:     opts.LaunchExe,
+			LaunchCommand: opts.LaunchCommand,
+			BuildId:       base64.RawURLEncoding.EncodeToString(guidBytes(id)),
+		},
+		ChunkDataList:    im.chunkList,
+		FileManifestList: fileList,
+		CustomFields:     &FCustomFields{Fields: map[string]string{}},
+	}, nil
+}
+
+// ImportTar packs the files of a tar archive into chunks written to out and returns the manifest of the build.
+// Symlinks and hard links are kept and permissions are mapped to the meta flags.
+// Save the manifest with WriteManifest.
+func ImportTar(r io.Reader, out ChunkWriter, opts ImportOptions) (*BinaryManifest, error) {
+	im := newImporter(out, opts.WindowSize)
+	tr := tar.NewReader(r)
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeReg, tar.TypeRegA:
+			err = im.addFile(header.Name, header.FileInfo().Mode(), tr)
+		case tar.TypeSymlink:
+			im.addSymlink(header.Name, header.Linkname)
+		case tar.TypeLink:
+			err = im.addHardlink(header.Name, header.Linkname, header.FileInfo().Mode())
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return im.finish(opts)
+}
+
+// ImportZip packs the files of a zip archive into chunks, see ImportTar.
+func ImportZip(r io.ReaderAt, size int64, out ChunkWriter, opts ImportOptions) (*BinaryManifest, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	im := newImporter(out, opts.WindowSize)
+
+	for _, entry := range zr.File {
+		mode := entry.Mode()
+		if mode.IsDir() {
+			continue
+		}
+
+		fh, err := entry.Open()
+		if err != nil {
+			return nil, err
+		}
+
+		if mode&os.ModeSymlink != 0 {
+			var target []byte
+			target, err = ioutil.ReadAll(fh)
+			im.addSymlink(entry.Name, string(target))
+		} else {
+			err = im.addFile(entry.Name, mode, fh)
+		}
+		fh.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return im.finish(opts)
+}
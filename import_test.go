@@ -0,0 +1,167 @@
+package egmanifest
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// importTestFiles are the regular files of the test archives.
+var importTestFiles = []struct {
+	name string
+	mode os.FileMode
+	data []byte
+}{
+	{"bin/tool", 0755, bytes.Repeat([]byte("tool "), 140)},
+	{"data/a.bin", 0644, bytes.Repeat([]byte{1, 2, 3}, 100)},
+	{"data/empty", 0644, nil},
+	// starts in the middle of a chunk and spans several
+	{"data/b.bin", 0644, bytes.Repeat([]byte("data "), 120)},
+}
+
+// checkImport writes manifest, parses it back and installs it from src.
+func checkImport(t *testing.T, manifest *BinaryManifest, src memSource, links map[string]string) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	err := WriteManifest(&buf, manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseManifest(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed.Header, manifest.Header) || !reflect.DeepEqual(parsed.Metadata, manifest.Metadata) {
+		t.Errorf("parsed header or metadata doesn't match the written one")
+	}
+	if !reflect.DeepEqual(parsed.ChunkDataList, manifest.ChunkDataList) {
+		t.Errorf("parsed chunk list doesn't match the written one")
+	}
+	if len(src) != len(parsed.ChunkDataList.Chunks) {
+		t.Errorf("%d chunks were stored for %d in the manifest", len(src), len(parsed.ChunkDataList.Chunks))
+	}
+
+	dir := t.TempDir()
+	err = Install(context.Background(), parsed, dir, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := VerifyInstall(parsed, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() || len(report.Extra) != 0 {
+		t.Errorf("install doesn't verify: %v, extra %v", report.Results, report.Extra)
+	}
+
+	for _, file := range importTestFiles {
+		path := filepath.Join(dir, filepath.FromSlash(file.name))
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, file.data) {
+			t.Errorf("%s: installed contents don't match the archive", file.name)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exec := info.Mode()&0111 != 0; exec != (file.mode&0111 != 0) {
+			t.Errorf("%s: executable %v, want %v", file.name, exec, !exec)
+		}
+	}
+	for name, target := range links {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		got, err := os.Readlink(path)
+		if err != nil {
+			// hard links are installed as copies
+			got, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, _ := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(target)))
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s: contents don't match %s", name, target)
+			}
+			continue
+		}
+		if got != target {
+			t.Errorf("%s points to %q, want %q", name, got, target)
+		}
+	}
+}
+
+func TestImportTar(t *testing.T) {
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	for _, file := range importTestFiles {
+		tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: file.name, Mode: int64(file.mode), Size: int64(len(file.data))})
+		tw.Write(file.data)
+	}
+	tw.WriteHeader(&tar.Header{Typeflag: tar.TypeSymlink, Name: "link", Linkname: "bin/tool"})
+	tw.WriteHeader(&tar.Header{Typeflag: tar.TypeLink, Name: "bin/hardlink", Linkname: "bin/tool", Mode: 0755})
+	tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: "dir/", Mode: 0755})
+	err := tw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := memSource{}
+	manifest, err := ImportTar(&archive, src, ImportOptions{AppName: "Imported", BuildVersion: "1.0", WindowSize: 256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count := len(manifest.FileManifestList.FileManifestList); count != len(importTestFiles)+2 {
+		t.Errorf("imported %d files, want %d", count, len(importTestFiles)+2)
+	}
+	checkImport(t, manifest, src, map[string]string{"link": "bin/tool", "bin/hardlink": "bin/tool"})
+}
+
+func TestImportZip(t *testing.T) {
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	for _, file := range importTestFiles {
+		header := &zip.FileHeader{Name: file.name, Method: zip.Deflate}
+		header.SetMode(file.mode)
+		fw, err := zw.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write(file.data)
+	}
+	header := &zip.FileHeader{Name: "link"}
+	header.SetMode(os.ModeSymlink | 0777)
+	fw, err := zw.CreateHeader(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write([]byte("bin/tool"))
+	header = &zip.FileHeader{Name: "dir/"}
+	header.SetMode(os.ModeDir | 0755)
+	_, err = zw.CreateHeader(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = zw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := memSource{}
+	manifest, err := ImportZip(bytes.NewReader(archive.Bytes()), int64(archive.Len()), src, ImportOptions{WindowSize: 256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count := len(manifest.FileManifestList.FileManifestList); count != len(importTestFiles)+1 {
+		t.Errorf("imported %d files, want %d", count, len(importTestFiles)+1)
+	}
+	checkImport(t, manifest, src, map[string]string{"link": "bin/tool"})
+}
@@ -0,0 +1,129 @@
+package egmanifest
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// Install installs every file of manifest into dir, see InstallFiles.
+func Install(ctx context.Context, manifest *BinaryManifest, dir string, src ChunkSource) error {
+	return InstallFiles(ctx, manifest, manifest.FileManifestList.Files(), dir, src)
+}
+
+// InstallFiles installs files from manifest into dir.
+// Progress is recorded in a journal inside dir, so an interrupted install picks up where it
+// stopped without downloading or writing the completed chunks and files again.
+func InstallFiles(ctx context.Context, manifest *BinaryManifest, files []*File, dir string, src ChunkSource) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	paths, err := installPaths(dir, files, manifest.FileManifestList.Files())
+	if err != nil {
+		return err
+	}
+
+	journal, err := OpenJournal(dir, manifest, files)
+	if err != nil {
+		return err
+	}
+	defer journal.Close()
+
+	var pending []*File
+	for _, file := range files {
+		if journal.FileDone(file.FileName) {
+			continue
+		}
+		pending = append(pending, file)
+
+		if journal.IsPrepared(file.FileName) {
+			continue
+		}
+		err = prepareFile(paths[file], file)
+		if err != nil {
+			return err
+		}
+		err = journal.MarkPrepared(file.FileName)
+		if err != nil {
+			return err
+		}
+	}
+	err = journal.Flush()
+	if err != nil {
+		return err
+	}
+
+	cr := collectChunkRefs(pending, func(file *File) string {
+		return paths[file]
+	})
+	var todo []*Chunk
+	for _, chunk := range cr.order {
+		if !journal.ChunkDone(chunk.GUID) {
+			todo = append(todo, chunk)
+		}
+	}
+
+	err = fetchChunkData(ctx, src, todo, func(chunk *Chunk, data []byte) error {
+		err := writeChunkParts(data, cr.refs[chunk.GUID])
+		if err != nil {
+			return err
+		}
+		return journal.MarkChunk(chunk.GUID)
+	})
+	if err != nil {
+		return err
+	}
+
+	// every file is checked before it's marked as done, this also catches
+	// writes that were journaled but didn't reach the disk before a crash
+	broken, err := finishFiles(paths, pending, journal)
+	if err != nil {
+		return err
+	}
+	if len(broken) != 0 {
+		err = assembleFiles(ctx, paths, broken, src)
+		if err != nil {
+			return err
+		}
+
+		broken, err = finishFiles(paths, broken, journal)
+		if err != nil {
+			return err
+		}
+		if len(broken) != 0 {
+			return fmt.Errorf("%s does not match the manifest after being rebuilt", broken[0].FileName)
+		}
+	}
+
+	return journal.Remove()
+}
+
+// finishFiles applies permissions to freshly written files and verifies them,
+// returning the ones that don't match the manifest.
+func finishFiles(paths map[*File]string, files []*File, journal *InstallJournal) ([]*File, error) {
+	var broken []*File
+	for _, file := range files {
+		path := paths[file]
+		err := finishFile(path, file)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		status, err := verifyPath(path, file)
+		if err != nil {
+			return nil, err
+		}
+		if status != FileOK {
+			broken = append(broken, file)
+			continue
+		}
+
+		err = journal.MarkDone(file.FileName)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return broken, nil
+}
@@ -0,0 +1,128 @@
+package egmanifest
+
+import (
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+// InstallSize describes how much a set of files takes to download and install.
+type InstallSize struct {
+	FileCount  int
+	ChunkCount int
+	// sum of the sizes of all the files
+	InstallSize uint64
+	// sum of Chunk.FileSize for every unique chunk the files reference
+	DownloadSize uint64
+}
+
+// isAlwaysInstalled reports whether a file is installed regardless of the selected tags.
+// Unreal treats untagged files and files with an empty tag as required.
+func isAlwaysInstalled(file *File) bool {
+	if len(file.InstallTags) == 0 {
+		return true
+	}
+	for _, tag := range file.InstallTags {
+		if tag == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// InstallTags returns every non-empty install tag used in the list, sorted.
+func (l *FFileManifestList) InstallTags() []string {
+	seen := map[string]struct{}{}
+	for idx := range l.FileManifestList {
+		for _, tag := range l.FileManifestList[idx].InstallTags {
+			if tag != "" {
+				seen[tag] = struct{}{}
+			}
+		}
+	}
+
+	tags := make([]string, 0, len(seen))
+	for tag := range seen {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// SelectFiles returns the files that get installed when the given tags are selected.
+// Files with no tags or an empty tag are always selected.
+func (l *FFileManifestList) SelectFiles(tags []string) []*File {
+	selected := make(map[string]struct{}, len(tags))
+	for _, tag := range tags {
+		selected[tag] = struct{}{}
+	}
+
+	var out []*File
+	for idx := range l.FileManifestList {
+		file := &l.FileManifestList[idx]
+		if isAlwaysInstalled(file) {
+			out = append(out, file)
+			continue
+		}
+		for _, tag := range file.InstallTags {
+			if _, ok := selected[tag]; ok {
+				out = append(out, file)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// FilesWithTag returns the files that carry tag, an empty tag returns the always installed files.
+func (l *FFileManifestList) FilesWithTag(tag string) []*File {
+	var out []*File
+	for idx := range l.FileManifestList {
+		file := &l.FileManifestList[idx]
+		if tag == "" {
+			if isAlwaysInstalled(file) {
+				out = append(out, file)
+			}
+			continue
+		}
+		for _, fileTag := range file.InstallTags {
+			if fileTag == tag {
+				out = append(out, file)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// ComputeInstallSize sums up the install and download sizes of files.
+func ComputeInstallSize(files []*File) InstallSize {
+	size := InstallSize{FileCount: len(files)}
+	seen := map[uuid.UUID]struct{}{}
+	for _, file := range files {
+		size.InstallSize += file.Size()
+		for _, part := range file.ChunkParts {
+			if _, ok := seen[part.ParentGUID]; ok {
+				continue
+			}
+			seen[part.ParentGUID] = struct{}{}
+			size.ChunkCount++
+			if part.Chunk != nil {
+				size.DownloadSize += part.Chunk.FileSize
+			}
+		}
+	}
+	return size
+}
+
+// TagSizes returns the sizes of the files carrying each install tag.
+// The always installed files are reported under the empty tag.
+func (l *FFileManifestList) TagSizes() map[string]InstallSize {
+	out := map[string]InstallSize{
+		"": ComputeInstallSize(l.FilesWithTag("")),
+	}
+	for _, tag := range l.InstallTags() {
+		out[tag] = ComputeInstallSize(l.FilesWithTag(tag))
+	}
+	return out
+}
@@ -0,0 +1,53 @@
+package egmanifest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func fileNames(files []*File) []string {
+	names := make([]string, len(files))
+	for idx, file := range files {
+		names[idx] = file.FileName
+	}
+	return names
+}
+
+func TestSelectFiles(t *testing.T) {
+	manifest, _ := testManifest()
+	list := manifest.FileManifestList
+	// an empty tag makes a file required whatever its other tags
+	list.FileManifestList = append(list.FileManifestList, File{FileName: "required.pak", InstallTags: []string{"", "de"}})
+
+	if tags, want := list.InstallTags(), []string{"de", "fr", "hd"}; !reflect.DeepEqual(tags, want) {
+		t.Errorf("InstallTags = %v, want %v", tags, want)
+	}
+
+	always := []string{"bin/game.exe", "link", "required.pak"}
+	tests := []struct {
+		tags []string
+		want []string
+	}{
+		{nil, always},
+		{[]string{}, always},
+		{[]string{"unknown"}, always},
+		{[]string{""}, always},
+		{[]string{"hd", "unknown"}, []string{"bin/game.exe", "data/a.pak", "link", "required.pak"}},
+		{[]string{"fr", "hd", "de"}, []string{"bin/game.exe", "data/a.pak", "data/b.pak", "link", "required.pak"}},
+	}
+	for _, test := range tests {
+		if names := fileNames(list.SelectFiles(test.tags)); !reflect.DeepEqual(names, test.want) {
+			t.Errorf("SelectFiles(%q) = %v, want %v", test.tags, names, test.want)
+		}
+	}
+
+	if names := fileNames(list.FilesWithTag("")); !reflect.DeepEqual(names, always) {
+		t.Errorf("FilesWithTag(\"\") = %v, want %v", names, always)
+	}
+	if names := fileNames(list.FilesWithTag("de")); !reflect.DeepEqual(names, []string{"required.pak"}) {
+		t.Errorf("FilesWithTag(\"de\") = %v, want [required.pak]", names)
+	}
+	if files := list.FilesWithTag("unknown"); len(files) != 0 {
+		t.Errorf("FilesWithTag(\"unknown\") = %v, want none", fileNames(files))
+	}
+}
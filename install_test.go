@@ -0,0 +1,75 @@
+package egmanifest
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInstall(t *testing.T) {
+	manifest, src := testManifest()
+	dir := t.TempDir()
+
+	counter := &countingSource{memSource: src}
+	err := Install(context.Background(), manifest, dir, counter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counter.fetched != 2 {
+		t.Errorf("fetched %d chunks, want 2", counter.fetched)
+	}
+
+	report, err := VerifyInstall(manifest, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() || len(report.Extra) != 0 {
+		t.Errorf("install doesn't verify: %v, extra %v", report.Results, report.Extra)
+	}
+}
+
+func TestInstallPath(t *testing.T) {
+	dir := filepath.Join("install", "dir")
+	for _, name := range []string{"", ".", "..", "../escaped.txt", "a/../../escaped.txt", "/etc/passwd"} {
+		_, err := installPath(dir, name)
+		if !errors.Is(err, ErrUnsafePath) {
+			t.Errorf("installPath(%q) = %v, want ErrUnsafePath", name, err)
+		}
+	}
+
+	path, err := installPath(dir, "a/../b/c.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "b", "c.txt"); path != want {
+		t.Errorf("installPath = %q, want %q", path, want)
+	}
+}
+
+func TestInstallRefusesEscapes(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "install")
+
+	manifest, src := testManifest()
+	manifest.FileManifestList.FileManifestList[1].FileName = "../escaped.txt"
+	err := Install(context.Background(), manifest, dir, src)
+	if !errors.Is(err, ErrUnsafePath) {
+		t.Errorf("Install = %v, want ErrUnsafePath", err)
+	}
+
+	// a symlink out of the install dir followed by a file written through it
+	manifest, src = testManifest()
+	files := manifest.FileManifestList.FileManifestList
+	files[3].SymlinkTarget = root
+	files[1].FileName = "link/escaped.txt"
+	err = Install(context.Background(), manifest, dir, src)
+	if !errors.Is(err, ErrUnsafePath) {
+		t.Errorf("Install through a symlink = %v, want ErrUnsafePath", err)
+	}
+
+	if _, err := os.Lstat(filepath.Join(root, "escaped.txt")); !os.IsNotExist(err) {
+		t.Errorf("a file was written outside of the install dir")
+	}
+}
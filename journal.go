@@ -0,0 +1,236 @@
+package egmanifest
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// JournalFileName is the name of the install journal inside the install directory.
+const JournalFileName = ".egmanifest.journal"
+
+// journalEntry is a single line of the journal, only one of its fields is set.
+type journalEntry struct {
+	// identifies the build and file selection the journal belongs to, always the first line
+	Build string `json:"build,omitempty"`
+	// the file was created with its final size
+	Prepared string `json:"prepared,omitempty"`
+	// all the parts of this chunk were written to their files
+	Chunk *uuid.UUID `json:"chunk,omitempty"`
+	// the file was verified and is complete
+	Done string `json:"done,omitempty"`
+}
+
+// InstallJournal records the progress of an install so that it can be resumed.
+// It's an append only file of JSON lines, a torn last line from a crash is ignored.
+type InstallJournal struct {
+	mu     sync.Mutex
+	fh     *os.File
+	buf    *bufio.Writer
+	closed bool
+
+	prepared map[string]struct{}
+	chunks   map[uuid.UUID]struct{}
+	done     map[string]struct{}
+}
+
+// journalBuildID identifies a manifest and the files selected from it.
+func journalBuildID(manifest *BinaryManifest, files []*File) string {
+	names := make([]string, len(files))
+	for idx, file := range files {
+		names[idx] = file.FileName
+	}
+	sort.Strings(names)
+
+	hasher := sha1.New()
+	for _, name := range names {
+		hasher.Write([]byte(name))
+		hasher.Write([]byte{0})
+	}
+
+	id := fmt.Sprintf("%x", manifest.Header.SHAHash)
+	if manifest.Metadata != nil {
+		id += "/" + manifest.Metadata.BuildId + "/" + manifest.Metadata.BuildVersion
+	}
+	return fmt.Sprintf("%s/%x", id, hasher.Sum(nil))
+}
+
+// OpenJournal opens the journal of an install of files from manifest into dir.
+// A journal left by an install of another build or file selection is discarded.
+func OpenJournal(dir string, manifest *BinaryManifest, files []*File) (*InstallJournal, error) {
+	j := &InstallJournal{
+		prepared: map[string]struct{}{},
+		chunks:   map[uuid.UUID]struct{}{},
+		done:     map[string]struct{}{},
+	}
+	buildID := journalBuildID(manifest, files)
+	path := filepath.Join(dir, JournalFileName)
+
+	fh, err := os.Open(path)
+	if err == nil {
+		valid := j.load(fh, buildID)
+		fh.Close()
+		if valid {
+			j.fh, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+			if err != nil {
+				return nil, err
+			}
+			j.buf = bufio.NewWriter(j.fh)
+			return j, nil
+		}
+		j.prepared = map[string]struct{}{}
+		j.chunks = map[uuid.UUID]struct{}{}
+		j.done = map[string]struct{}{}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	j.fh, err = os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	j.buf = bufio.NewWriter(j.fh)
+
+	err = j.append(journalEntry{Build: buildID})
+	if err != nil {
+		j.fh.Close()
+		return nil, err
+	}
+	return j, j.Flush()
+}
+
+// load reads the entries of an existing journal and reports whether it belongs to buildID.
+func (j *InstallJournal) load(fh *os.File, buildID string) bool {
+	scanner := bufio.NewScanner(fh)
+	scanner.Buffer(nil, 1<<20)
+
+	first := true
+	for scanner.Scan() {
+		var entry journalEntry
+		if json.Unmarshal(scanner.Bytes(), &entry) != nil {
+			// torn write, anything after it was appended by a later run
+			if first {
+				return false
+			}
+			continue
+		}
+
+		if first {
+			if entry.Build != buildID {
+				return false
+			}
+			first = false
+			continue
+		}
+
+		switch {
+		case entry.Prepared != "":
+			j.prepared[entry.Prepared] = struct{}{}
+		case entry.Chunk != nil:
+			j.chunks[*entry.Chunk] = struct{}{}
+		case entry.Done != "":
+			j.done[entry.Done] = struct{}{}
+		}
+	}
+	return !first
+}
+
+func (j *InstallJournal) append(entry journalEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = j.buf.Write(append(data, '\n'))
+	return err
+}
+
+// IsPrepared reports whether the file was already created.
+func (j *InstallJournal) IsPrepared(fileName string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	_, ok := j.prepared[fileName]
+	return ok
+}
+
+// ChunkDone reports whether all the parts of a chunk were already written.
+func (j *InstallJournal) ChunkDone(guid uuid.UUID) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	_, ok := j.chunks[guid]
+	return ok
+}
+
+// FileDone reports whether the file was already completed and verified.
+func (j *InstallJournal) FileDone(fileName string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	_, ok := j.done[fileName]
+	return ok
+}
+
+func (j *InstallJournal) MarkPrepared(fileName string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.prepared[fileName] = struct{}{}
+	return j.append(journalEntry{Prepared: fileName})
+}
+
+func (j *InstallJournal) MarkChunk(guid uuid.UUID) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.chunks[guid] = struct{}{}
+	err := j.append(journalEntry{Chunk: &guid})
+	if err != nil {
+		return err
+	}
+	return j.buf.Flush()
+}
+
+func (j *InstallJournal) MarkDone(fileName string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.done[fileName] = struct{}{}
+	err := j.append(journalEntry{Done: fileName})
+	if err != nil {
+		return err
+	}
+	return j.buf.Flush()
+}
+
+// Flush writes the buffered entries to the journal file.
+func (j *InstallJournal) Flush() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.buf.Flush()
+}
+
+func (j *InstallJournal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.closed {
+		return nil
+	}
+	j.closed = true
+
+	err := j.buf.Flush()
+	if closeErr := j.fh.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Remove closes and deletes the journal once the install is complete.
+func (j *InstallJournal) Remove() error {
+	err := j.Close()
+	if err != nil {
+		return err
+	}
+	return os.Remove(j.fh.Name())
+}
@@ -0,0 +1,91 @@
+package egmanifest
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// interruptedInstall installs manifest into dir, failing after the first chunk.
+func interruptedInstall(t *testing.T, manifest *BinaryManifest, dir string, src memSource) {
+	t.Helper()
+	err := Install(context.Background(), manifest, dir, &countingSource{memSource: src, limit: 1})
+	if !errors.Is(err, errFetchLimit) {
+		t.Fatalf("interrupted install = %v, want errFetchLimit", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, JournalFileName)); err != nil {
+		t.Fatalf("no journal after an interrupted install: %v", err)
+	}
+}
+
+func TestInstallResume(t *testing.T) {
+	manifest, src := testManifest()
+	dir := t.TempDir()
+	interruptedInstall(t, manifest, dir, src)
+
+	counter := &countingSource{memSource: src}
+	err := Install(context.Background(), manifest, dir, counter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counter.fetched != 1 {
+		t.Errorf("resume fetched %d chunks, want only the one that wasn't written", counter.fetched)
+	}
+
+	report, err := VerifyInstall(manifest, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() {
+		t.Errorf("resumed install doesn't verify: %v", report.Results)
+	}
+	if _, err := os.Stat(filepath.Join(dir, JournalFileName)); !os.IsNotExist(err) {
+		t.Errorf("journal wasn't removed after the install: %v", err)
+	}
+}
+
+func TestInstallResumeOtherBuild(t *testing.T) {
+	manifest, src := testManifest()
+	dir := t.TempDir()
+	interruptedInstall(t, manifest, dir, src)
+
+	meta := *manifest.Metadata
+	meta.BuildVersion = "2.0"
+	other := *manifest
+	other.Metadata = &meta
+
+	counter := &countingSource{memSource: src}
+	err := Install(context.Background(), &other, dir, counter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counter.fetched != 2 {
+		t.Errorf("install of another build fetched %d chunks, want all of them", counter.fetched)
+	}
+}
+
+func TestInstallResumeOtherFiles(t *testing.T) {
+	manifest, src := testManifest()
+	dir := t.TempDir()
+	interruptedInstall(t, manifest, dir, src)
+
+	// bin/game.exe alone still needs both chunks
+	files := manifest.FileManifestList.Files()[:1]
+	counter := &countingSource{memSource: src}
+	err := InstallFiles(context.Background(), manifest, files, dir, counter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counter.fetched != 2 {
+		t.Errorf("install of other files fetched %d chunks, want all of them", counter.fetched)
+	}
+	status, err := VerifyFile(dir, files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != FileOK {
+		t.Errorf("%s: status %s after the install", files[0].FileName, status)
+	}
+}
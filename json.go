@@ -0,0 +1,538 @@
+package egmanifest
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// JSON encoding of manifests
+//
+// BinaryManifest and its sections implement json.Marshaler and json.Unmarshaler with a stable layout:
+//
+//	{
+//	  "header": {"headerSize", "dataSizeUncompressed", "dataSizeCompressed", "shaHash", "storedAs", "version", "versionName"},
+//	  "meta": {"dataSize", "dataVersion", "featureLevel", "featureLevelName", "isFileData", "appId", "appName",
+//	           "buildVersion", "launchExe", "launchCommand", "prereqIds", "prereqName", "prereqPath", "prereqArgs", "buildId"},
+//	  "chunkDataList": {"dataSize", "dataVersion", "chunks": [{"guid", "hash", "shaHash", "group", "windowSize", "fileSize"}]},
+//	  "fileManifestList": {"dataSize", "dataVersion", "files": [{"fileName", "symlinkTarget", "shaHash", "fileMetaFlags",
+//	                       "installTags", "chunkParts": [{"dataSize", "guid", "offset", "size"}]}]},
+//	  "customFields": {"dataSize", "dataVersion", "fields": {"key": "value"}, "entries": [{"key", "value", "keyUtf16", "valueUtf16"}]}
+//	}
+//
+// GUIDs are written as 32 uppercase hex digits like in chunk URLs, SHA hashes as lowercase hex
+// and Chunk.Hash as 16 uppercase hex digits since it doesn't fit in a JSON number.
+// Chunk parts reference their chunk by GUID, decoding a BinaryManifest links them back to the *Chunk.
+// Custom fields are written both as an object and as "entries", which keeps their order and duplicate keys.
+// The "versionName" and "featureLevelName" fields are informational and ignored while decoding.
+//
+// There's no YAML encoding, it would need a dependency this module doesn't have. JSON is valid YAML,
+// so the output can be read by YAML tools as is or converted by them.
+
+// emptyToNil returns nil for an empty array like the binary parser, which encoding writes as [].
+func emptyToNil(s []string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	return s
+}
+
+// jsonGUID encodes a GUID the way chunk URLs do.
+type jsonGUID uuid.UUID
+
+func (g jsonGUID) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%X", g[:])), nil
+}
+
+func (g *jsonGUID) UnmarshalText(text []byte) error {
+	if len(text) == 32 {
+		_, err := hex.Decode(g[:], text)
+		return err
+	}
+	// be lenient and accept the dashed form too
+	guid, err := uuid.ParseBytes(text)
+	if err != nil {
+		return err
+	}
+	*g = jsonGUID(guid)
+	return nil
+}
+
+type jsonSHA [20]byte
+
+func (h jsonSHA) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(h[:])), nil
+}
+
+func (h *jsonSHA) UnmarshalText(text []byte) error {
+	if hex.DecodedLen(len(text)) != len(h) {
+		return fmt.Errorf("invalid SHA hash length %d", len(text))
+	}
+	_, err := hex.Decode(h[:], text)
+	return err
+}
+
+type jsonHash uint64
+
+func (h jsonHash) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%016X", uint64(h))), nil
+}
+
+func (h *jsonHash) UnmarshalText(text []byte) error {
+	v, err := strconv.ParseUint(string(text), 16, 64)
+	*h = jsonHash(v)
+	return err
+}
+
+type jsonHeader struct {
+	HeaderSize           int32   `json:"headerSize"`
+	DataSizeUncompressed int32   `json:"dataSizeUncompressed"`
+	DataSizeCompressed   int32   `json:"dataSizeCompressed"`
+	SHAHash              jsonSHA `json:"shaHash"`
+	StoredAs             uint8   `json:"storedAs"`
+	Version              int32   `json:"version"`
+	VersionName          string  `json:"versionName,omitempty"`
+}
+
+func (h FManifestHeader) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonHeader{
+		HeaderSize:           h.HeaderSize,
+		DataSizeUncompressed: h.DataSizeUncompressed,
+		DataSizeCompressed:   h.DataSizeCompressed,
+		SHAHash:              h.SHAHash,
+		StoredAs:             h.StoredAs,
+		Version:              int32(h.Version),
+		VersionName:          h.Version.String(),
+	})
+}
+
+func (h *FManifestHeader) UnmarshalJSON(data []byte) error {
+	var j jsonHeader
+	err := json.Unmarshal(data, &j)
+	if err != nil {
+		return err
+	}
+
+	*h = FManifestHeader{
+		HeaderSize:           j.HeaderSize,
+		DataSizeUncompressed: j.DataSizeUncompressed,
+		DataSizeCompressed:   j.DataSizeCompressed,
+		SHAHash:              j.SHAHash,
+		StoredAs:             j.StoredAs,
+		Version:              EFeatureLevel(j.Version),
+	}
+	return nil
+}
+
+type jsonMeta struct {
+	DataSize         uint32   `json:"dataSize"`
+	DataVersion      uint8    `json:"dataVersion"`
+	FeatureLevel     int32    `json:"featureLevel"`
+	FeatureLevelName string   `json:"featureLevelName,omitempty"`
+	IsFileData       bool     `json:"isFileData"`
+	AppID            int32    `json:"appId"`
+	AppName          string   `json:"appName"`
+	BuildVersion     string   `json:"buildVersion"`
+	LaunchExe        string   `json:"launchExe"`
+	LaunchCommand    string   `json:"launchCommand"`
+	PrereqIds        []string `json:"prereqIds"`
+	PrereqName       string   `json:"prereqName"`
+	PrereqPath       string   `json:"prereqPath"`
+	PrereqArgs       string   `json:"prereqArgs"`
+	BuildId          string   `json:"buildId"`
+}
+
+func (m FManifestMeta) MarshalJSON() ([]byte, error) {
+	prereqIds := m.PrereqIds
+	if prereqIds == nil {
+		prereqIds = []string{}
+	}
+
+	return json.Marshal(jsonMeta{
+		DataSize:         m.DataSize,
+		DataVersion:      m.DataVersion,
+		FeatureLevel:     int32(m.FeatureLevel),
+		FeatureLevelName: m.FeatureLevel.String(),
+		IsFileData:       m.IsFileData,
+		AppID:            m.AppID,
+		AppName:          m.AppName,
+		BuildVersion:     m.BuildVersion,
+		LaunchExe:        m.LaunchExe,
+		LaunchCommand:    m.LaunchCommand,
+		PrereqIds:        prereqIds,
+		PrereqName:       m.PrereqName,
+		PrereqPath:       m.PrereqPath,
+		PrereqArgs:       m.PrereqArgs,
+		BuildId:          m.BuildId,
+	})
+}
+
+func (m *FManifestMeta) UnmarshalJSON(data []byte) error {
+	var j jsonMeta
+	err := json.Unmarshal(data, &j)
+	if err != nil {
+		return err
+	}
+
+	*m = FManifestMeta{
+		DataSize:      j.DataSize,
+		DataVersion:   j.DataVersion,
+		FeatureLevel:  EFeatureLevel(j.FeatureLevel),
+		IsFileData:    j.IsFileData,
+		AppID:         j.AppID,
+		AppName:       j.AppName,
+		BuildVersion:  j.BuildVersion,
+		LaunchExe:     j.LaunchExe,
+		LaunchCommand: j.LaunchCommand,
+		PrereqIds:     emptyToNil(j.PrereqIds),
+		PrereqName:    j.PrereqName,
+		PrereqPath:    j.PrereqPath,
+		PrereqArgs:    j.PrereqArgs,
+		BuildId:       j.BuildId,
+	}
+	return nil
+}
+
+type jsonChunk struct {
+	GUID       jsonGUID `json:"guid"`
+	Hash       jsonHash `json:"hash"`
+	SHAHash    jsonSHA  `json:"shaHash"`
+	Group      uint8    `json:"group"`
+	WindowSize uint32   `json:"windowSize"`
+	FileSize   uint64   `json:"fileSize"`
+}
+
+func (c Chunk) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonChunk{
+		GUID:       jsonGUID(c.GUID),
+		Hash:       jsonHash(c.Hash),
+		SHAHash:    c.SHAHash,
+		Group:      c.Group,
+		WindowSize: c.WindowSize,
+		FileSize:   c.FileSize,
+	})
+}
+
+func (c *Chunk) UnmarshalJSON(data []byte) error {
+	var j jsonChunk
+	err := json.Unmarshal(data, &j)
+	if err != nil {
+		return err
+	}
+
+	*c = Chunk{
+		GUID:       uuid.UUID(j.GUID),
+		Hash:       uint64(j.Hash),
+		SHAHash:    j.SHAHash,
+		Group:      j.Group,
+		WindowSize: j.WindowSize,
+		FileSize:   j.FileSize,
+	}
+	return nil
+}
+
+type jsonChunkDataList struct {
+	DataSize    uint32   `json:"dataSize"`
+	DataVersion uint8    `json:"dataVersion"`
+	Chunks      []*Chunk `json:"chunks"`
+}
+
+func (l FChunkDataList) MarshalJSON() ([]byte, error) {
+	chunks := l.Chunks
+	if chunks == nil {
+		chunks = []*Chunk{}
+	}
+
+	return json.Marshal(jsonChunkDataList{
+		DataSize:    l.DataSize,
+		DataVersion: l.DataVersion,
+		Chunks:      chunks,
+	})
+}
+
+func (l *FChunkDataList) UnmarshalJSON(data []byte) error {
+	var j jsonChunkDataList
+	err := json.Unmarshal(data, &j)
+	if err != nil {
+		return err
+	}
+
+	*l = FChunkDataList{
+		DataSize:    j.DataSize,
+		DataVersion: j.DataVersion,
+		Count:       uint32(len(j.Chunks)),
+		Chunks:      j.Chunks,
+		ChunkLookup: make(map[uuid.UUID]uint32, len(j.Chunks)),
+	}
+	for idx, chunk := range l.Chunks {
+		if chunk == nil {
+			return fmt.Errorf("chunk %d is null", idx)
+		}
+		l.ChunkLookup[chunk.GUID] = uint32(idx)
+	}
+	return nil
+}
+
+type jsonChunkPart struct {
+	DataSize uint32   `json:"dataSize"`
+	GUID     jsonGUID `json:"guid"`
+	Offset   uint32   `json:"offset"`
+	Size     uint32   `json:"size"`
+}
+
+// MarshalJSON encodes the part, referencing its chunk by GUID.
+func (p ChunkPart) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonChunkPart{
+		DataSize: p.DataSize,
+		GUID:     jsonGUID(p.ParentGUID),
+		Offset:   p.Offset,
+		Size:     p.Size,
+	})
+}
+
+// UnmarshalJSON decodes the part, leaving Chunk nil. Decoding a BinaryManifest links it.
+func (p *ChunkPart) UnmarshalJSON(data []byte) error {
+	var j jsonChunkPart
+	err := json.Unmarshal(data, &j)
+	if err != nil {
+		return err
+	}
+
+	*p = ChunkPart{
+		DataSize:   j.DataSize,
+		ParentGUID: uuid.UUID(j.GUID),
+		Offset:     j.Offset,
+		Size:       j.Size,
+	}
+	return nil
+}
+
+type jsonFile struct {
+	FileName      string      `json:"fileName"`
+	SymlinkTarget string      `json:"symlinkTarget"`
+	SHAHash       jsonSHA     `json:"shaHash"`
+	FileMetaFlags uint8       `json:"fileMetaFlags"`
+	InstallTags   []string    `json:"installTags"`
+	ChunkParts    []ChunkPart `json:"chunkParts"`
+}
+
+func (f File) MarshalJSON() ([]byte, error) {
+	j := jsonFile{
+		FileName:      f.FileName,
+		SymlinkTarget: f.SymlinkTarget,
+		SHAHash:       f.SHAHash,
+		FileMetaFlags: uint8(f.FileMetaFlags),
+		InstallTags:   f.InstallTags,
+		ChunkParts:    f.ChunkParts,
+	}
+	if j.InstallTags == nil {
+		j.InstallTags = []string{}
+	}
+	if j.ChunkParts == nil {
+		j.ChunkParts = []ChunkPart{}
+	}
+	return json.Marshal(j)
+}
+
+func (f *File) UnmarshalJSON(data []byte) error {
+	var j jsonFile
+	err := json.Unmarshal(data, &j)
+	if err != nil {
+		return err
+	}
+
+	*f = File{
+		FileName:      j.FileName,
+		SymlinkTarget: j.SymlinkTarget,
+		SHAHash:       j.SHAHash,
+		FileMetaFlags: EFileMetaFlags(j.FileMetaFlags),
+		InstallTags:   emptyToNil(j.InstallTags),
+		ChunkParts:    j.ChunkParts,
+	}
+	return nil
+}
+
+type jsonFileManifestList struct {
+	DataSize    uint32 `json:"dataSize"`
+	DataVersion uint8  `json:"dataVersion"`
+	Files       []File `json:"files"`
+}
+
+func (l FFileManifestList) MarshalJSON() ([]byte, error) {
+	files := l.FileManifestList
+	if files == nil {
+		files = []File{}
+	}
+
+	return json.Marshal(jsonFileManifestList{
+		DataSize:    l.DataSize,
+		DataVersion: l.DataVersion,
+		Files:       files,
+	})
+}
+
+func (l *FFileManifestList) UnmarshalJSON(data []byte) error {
+	var j jsonFileManifestList
+	err := json.Unmarshal(data, &j)
+	if err != nil {
+		return err
+	}
+
+	*l = FFileManifestList{
+		DataSize:         j.DataSize,
+		DataVersion:      j.DataVersion,
+		Count:            uint32(len(j.Files)),
+		FileManifestList: j.Files,
+	}
+	return nil
+}
+
+// LinkChunks points the chunk parts of the files at their chunks in dataList.
+func (l *FFileManifestList) LinkChunks(dataList *FChunkDataList) error {
+	for idx := range l.FileManifestList {
+		file := &l.FileManifestList[idx]
+		for cpIdx := range file.ChunkParts {
+			part := &file.ChunkParts[cpIdx]
+			chunkID, ok := dataList.ChunkLookup[part.ParentGUID]
+			if !ok {
+				return fmt.Errorf("in chunkPart %d for file %d: parent GUID (%s) not found", cpIdx, idx, part.ParentGUID.String())
+			}
+			part.Chunk = dataList.Chunks[chunkID]
+		}
+	}
+	return nil
+}
+
+type jsonCustomField struct {
+	Key        string `json:"key"`
+	Value      string `json:"value"`
+	KeyUTF16   bool   `json:"keyUtf16,omitempty"`
+	ValueUTF16 bool   `json:"valueUtf16,omitempty"`
+}
+
+type jsonCustomFields struct {
+	DataSize    uint32            `json:"dataSize"`
+	DataVersion uint8             `json:"dataVersion"`
+	Fields      map[string]string `json:"fields"`
+	Entries     []jsonCustomField `json:"entries,omitempty"`
+}
+
+func (f FCustomFields) MarshalJSON() ([]byte, error) {
+	fields := f.Fields
+	if fields == nil {
+		fields = map[string]string{}
+	}
+
+	entries := make([]jsonCustomField, len(f.Entries))
+	for idx, field := range f.Entries {
+		entries[idx] = jsonCustomField(field)
+	}
+
+	return json.Marshal(jsonCustomFields{
+		DataSize:    f.DataSize,
+		DataVersion: f.DataVersion,
+		Fields:      fields,
+		Entries:     entries,
+	})
+}
+
+// UnmarshalJSON decodes the fields from "entries" if present, otherwise from
+// "fields" with the keys sorted since JSON objects have no order.
+func (f *FCustomFields) UnmarshalJSON(data []byte) error {
+	var j jsonCustomFields
+	err := json.Unmarshal(data, &j)
+	if err != nil {
+		return err
+	}
+
+	*f = FCustomFields{
+		DataSize:    j.DataSize,
+		DataVersion: j.DataVersion,
+		Fields:      map[string]string{},
+	}
+	if j.Entries != nil {
+		for _, field := range j.Entries {
+			f.Entries = append(f.Entries, CustomField(field))
+			f.Fields[field.Key] = field.Value
+		}
+	} else {
+		keys := make([]string, 0, len(j.Fields))
+		for key := range j.Fields {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			f.Entries = append(f.Entries, CustomField{Key: key, Value: j.Fields[key]})
+			f.Fields[key] = j.Fields[key]
+		}
+	}
+	f.Count = uint32(len(f.Entries))
+	return nil
+}
+
+type jsonManifest struct {
+	Header           *FManifestHeader   `json:"header"`
+	Metadata         *FManifestMeta     `json:"meta"`
+	ChunkDataList    *FChunkDataList    `json:"chunkDataList"`
+	FileManifestList *FFileManifestList `json:"fileManifestList"`
+	CustomFields     *FCustomFields     `json:"customFields"`
+}
+
+func (m BinaryManifest) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonManifest(m))
+}
+
+// UnmarshalJSON decodes a manifest and links the chunk parts of its files to its chunks.
+func (m *BinaryManifest) UnmarshalJSON(data []byte) error {
+	var j jsonManifest
+	err := json.Unmarshal(data, &j)
+	if err != nil {
+		return err
+	}
+
+	if j.ChunkDataList != nil && j.FileManifestList != nil {
+		err = j.FileManifestList.LinkChunks(j.ChunkDataList)
+		if err != nil {
+			return err
+		}
+	}
+
+	*m = BinaryManifest(j)
+	return nil
+}
+
+// ParseManifestJSON decodes a manifest encoded by json.Marshal. The manifest
+// has to be complete, unlike with json.Unmarshal.
+func ParseManifestJSON(data []byte) (*BinaryManifest, error) {
+	var manifest BinaryManifest
+	err := json.Unmarshal(data, &manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	if manifest.Header == nil {
+		missing = append(missing, "header")
+	}
+	if manifest.Metadata == nil {
+		missing = append(missing, "meta")
+	}
+	if manifest.ChunkDataList == nil {
+		missing = append(missing, "chunkDataList")
+	}
+	if manifest.FileManifestList == nil {
+		missing = append(missing, "fileManifestList")
+	}
+	if manifest.CustomFields == nil {
+		missing = append(missing, "customFields")
+	}
+	if len(missing) != 0 {
+		return nil, fmt.Errorf("manifest JSON is missing %s", strings.Join(missing, ", "))
+	}
+	return &manifest, nil
+}
@@ -0,0 +1,41 @@
+package egmanifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestManifestJSONRoundTrip(t *testing.T) {
+	manifest, _ := testManifest()
+	manifest.CustomFields.Set(CustomFieldBaseURL, "http://cdn.example.com/Builds")
+	manifest.CustomFields.Entries = append(manifest.CustomFields.Entries, CustomField{Key: "Ünïcode", Value: "日本", KeyUTF16: true, ValueUTF16: true})
+	manifest.Metadata.PrereqIds = []string{"prereq"}
+	for _, source := range []*BinaryManifest{manifest, syntheticManifest(50)} {
+		parsed, err := ParseManifest(bytes.NewReader(encodeTestManifest(source, true)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := json.Marshal(parsed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := ParseManifestJSON(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(decoded, parsed) {
+			t.Errorf("%s: decoded manifest doesn't match the parsed one", parsed.Metadata.AppName)
+		}
+
+		again, err := json.Marshal(decoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(again, data) {
+			t.Errorf("%s: encoding the decoded manifest gives different JSON", parsed.Metadata.AppName)
+		}
+	}
+}
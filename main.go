@@ -3,17 +3,21 @@ package egmanifest
 import (
 	"bytes"
 	"compress/zlib"
+	"crypto/sha1"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
 
 	"github.com/er-azh/egmanifest/binreader"
+	"github.com/er-azh/egmanifest/binwriter"
 )
 
 var (
-	ErrBadMagic = errors.New("bad magic found, must be 0x44BEC00C")
+	ErrBadMagic         = errors.New("bad magic found, must be 0x44BEC00C")
+	ErrDataSizeMismatch = errors.New("decompressed data size mismatch")
 )
 
 const BinaryManifestMagic = 0x44BEC00C
@@ -27,84 +31,213 @@ type BinaryManifest struct {
 }
 
 func ParseManifest(f io.ReadSeeker) (*BinaryManifest, error) {
-	magic, err := binreader.NewReader(f, binary.LittleEndian).ReadUint32()
+	var manifest BinaryManifest
+	var reader io.ReadSeeker
+	var err error
+	manifest.Header, reader, err = openManifest(f)
 	if err != nil {
 		return nil, err
-	} else if magic != BinaryManifestMagic {
-		return nil, ErrBadMagic
 	}
 
-	var manifest BinaryManifest
-	manifest.Header, err = ParseHeader(f)
+	size, err := remainingSize(reader)
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = f.Seek(int64(manifest.Header.HeaderSize), io.SeekStart)
+	err = walkSections(reader, size, SectionsAll, &manifest, func(r io.ReadSeeker, dataList *FChunkDataList) (uint32, error) {
+		manifest.FileManifestList, err = ReadFileManifestList(r, dataList)
+		if err != nil {
+			return 0, err
+		}
+		return manifest.FileManifestList.DataSize, nil
+	})
 	if err != nil {
 		return nil, err
 	}
+	return &manifest, nil
+}
+
+// openManifest parses the header of a manifest file and returns a reader
+// positioned at the start of its (decompressed) data.
+func openManifest(f io.ReadSeeker) (*FManifestHeader, io.ReadSeeker, error) {
+	header, err := readManifestHeader(f)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	reader := f
-	if (manifest.Header.StoredAs & StoredCompressed) != 0 {
+	if (header.StoredAs & StoredCompressed) != 0 {
 		zreader, err := zlib.NewReader(reader)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		// TODO: avoid buffering the entire file
 		data, err := ioutil.ReadAll(zreader)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-		if len(data) != int(manifest.Header.DataSizeUncompressed) {
-			return nil, fmt.Errorf("decompressed data size mismatch, expected: %d and got: %d", len(data), manifest.Header.DataSizeUncompressed)
+		if len(data) != int(header.DataSizeUncompressed) {
+			return nil, nil, errDataSize(header, int64(len(data)))
 		}
 
 		reader = bytes.NewReader(data)
 	}
-	if (manifest.Header.StoredAs & StoredEncrypted) != 0 {
-		return nil, errors.New("manifest file is encrypted")
-	}
+	return header, reader, nil
+}
+
+func errDataSize(header *FManifestHeader, got int64) error {
+	return fmt.Errorf("%w, expected: %d and got: %d", ErrDataSizeMismatch, header.DataSizeUncompressed, got)
+}
 
-	currentPos, err := reader.Seek(0, io.SeekCurrent)
+// readManifestHeader checks the magic, parses the header and leaves f at the start of the data.
+func readManifestHeader(f io.ReadSeeker) (*FManifestHeader, error) {
+	magicReader := binreader.NewReader(f, binary.LittleEndian)
+	magic, err := magicReader.ReadUint32()
 	if err != nil {
 		return nil, err
+	} else if magic != BinaryManifestMagic {
+		return nil, ErrBadMagic
 	}
-
-	manifest.Metadata, err = ReadFManifestMeta(reader)
+	err = magicReader.Sync()
 	if err != nil {
 		return nil, err
 	}
 
-	currentPos, err = reader.Seek(currentPos+int64(manifest.Metadata.DataSize), io.SeekStart)
+	header, err := ParseHeader(f)
 	if err != nil {
 		return nil, err
 	}
+	if (header.StoredAs & StoredEncrypted) != 0 {
+		return nil, errors.New("manifest file is encrypted")
+	}
 
-	manifest.ChunkDataList, err = ReadChunkDataList(reader)
+	_, err = f.Seek(int64(header.HeaderSize), io.SeekStart)
 	if err != nil {
 		return nil, err
 	}
+	return header, nil
+}
+
+// WriteManifest writes m as a binary manifest file, compressing it if m.Header.StoredAs asks for it.
+// The header and the DataSize and Count fields of the sections are filled in to match what was written.
+func WriteManifest(w io.Writer, m *BinaryManifest) error {
+	if (m.Header.StoredAs & StoredEncrypted) != 0 {
+		return errors.New("can't write encrypted manifests")
+	}
 
-	currentPos, err = reader.Seek(currentPos+int64(manifest.ChunkDataList.DataSize), io.SeekStart)
+	body, err := encodeManifestBody(m)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	return writeManifestFile(w, m.Header, body)
+}
 
-	manifest.FileManifestList, err = ReadFileManifestList(reader, manifest.ChunkDataList)
+// encodeManifestBody serializes the sections of m in the order they're parsed.
+func encodeManifestBody(m *BinaryManifest) ([]byte, error) {
+	var buf binwriter.Buffer
+	err := WriteFManifestMeta(&buf, m.Metadata)
 	if err != nil {
 		return nil, err
 	}
-
-	_, err = reader.Seek(currentPos+int64(manifest.FileManifestList.DataSize), io.SeekStart)
+	err = WriteChunkDataList(&buf, m.ChunkDataList)
 	if err != nil {
 		return nil, err
 	}
-
-	manifest.CustomFields, err = ReadCustomFields(reader)
+	err = WriteFileManifestList(&buf, m.FileManifestList)
 	if err != nil {
 		return nil, err
 	}
-	return &manifest, nil
+	fields := m.CustomFields
+	if fields == nil {
+		fields = &FCustomFields{}
+	}
+	err = WriteCustomFields(&buf, fields)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeManifestFile fills in header for body and writes both, compressing body if header asks for it.
+func writeManifestFile(w io.Writer, header *FManifestHeader, body []byte) error {
+	if len(body) > math.MaxInt32 {
+		return errors.New("manifest data is too large")
+	}
+
+	header.HeaderSize = manifestHeaderSize
+	header.DataSizeUncompressed = int32(len(body))
+	header.SHAHash = sha1.Sum(body)
+	payload := body
+	if (header.StoredAs & StoredCompressed) != 0 {
+		var compressed bytes.Buffer
+		zwriter := zlib.NewWriter(&compressed)
+		_, err := zwriter.Write(body)
+		if err != nil {
+			return err
+		}
+		err = zwriter.Close()
+		if err != nil {
+			return err
+		}
+		payload = compressed.Bytes()
+	}
+	header.DataSizeCompressed = int32(len(payload))
+
+	err := WriteHeader(w, header)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(payload)
+	return err
+}
+
+// DataSubDir returns the sub directory of the CloudDir holding the build's data,
+// which depends on the feature level and on whether it's a file data build.
+func (m *BinaryManifest) DataSubDir() string {
+	if m.Metadata.IsFileData {
+		return m.Metadata.FeatureLevel.FileDataSubDir()
+	}
+	return m.Metadata.FeatureLevel.ChunkSubDir()
+}
+
+// DataPath returns the path of the data of c relative to DataSubDir,
+// following the naming and group numbers of the manifest's feature level.
+func (m *BinaryManifest) DataPath(c *Chunk) string {
+	if m.Metadata.IsFileData {
+		return c.FileDataPathFor(m.Metadata.FeatureLevel)
+	}
+	return c.PathFor(m.Metadata.FeatureLevel)
+}
+
+// GetDataURL returns the URL of the data of c in the CloudDir at cloudDir.
+// example for cloudDir: http://epicgames-download1.akamaized.net/Builds/Fortnite/CloudDir
+func (m *BinaryManifest) GetDataURL(cloudDir string, c *Chunk) string {
+	return fmt.Sprintf("%s/%s/%s", cloudDir, m.DataSubDir(), m.DataPath(c))
+}
+
+// DataDirs returns the directories holding the build's data on every CloudDir advertised in the
+// BaseUrl custom field. They can be used as mirrors, and passed to Chunk.GetURLFor for chunked builds.
+// It returns nil if the field is missing or the custom fields weren't parsed.
+func (m *BinaryManifest) DataDirs() []string {
+	baseURLs := m.CustomFields.BaseURLs()
+	if len(baseURLs) == 0 {
+		return nil
+	}
+
+	dirs := make([]string, len(baseURLs))
+	for idx, baseURL := range baseURLs {
+		dirs[idx] = baseURL + "/" + m.DataSubDir()
+	}
+	return dirs
+}
+
+// Mirrors returns DataDirs as mirrors, keeping the order of the manifest.
+func (m *BinaryManifest) Mirrors() []Mirror {
+	dirs := m.DataDirs()
+	mirrors := make([]Mirror, len(dirs))
+	for idx, dir := range dirs {
+		mirrors[idx] = Mirror{ChunksDir: dir}
+	}
+	return mirrors
 }
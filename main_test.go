@@ -0,0 +1,118 @@
+package egmanifest
+
+import (
+	"bytes"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// encodeTestBody serializes the sections of m and fills in their DataSize and Count fields.
+func encodeTestBody(m *BinaryManifest) []byte {
+	body, err := encodeManifestBody(m)
+	if err != nil {
+		panic(err)
+	}
+	return body
+}
+
+// encodeTestManifest serializes m as a manifest file and fills in its header.
+func encodeTestManifest(m *BinaryManifest, compress bool) []byte {
+	return encodeTestFile(m.Header, encodeTestBody(m), compress)
+}
+
+// encodeTestFile writes header followed by body as a manifest file, filling in the header.
+func encodeTestFile(header *FManifestHeader, body []byte, compress bool) []byte {
+	header.StoredAs = 0
+	if compress {
+		header.StoredAs = StoredCompressed
+	}
+	var buf bytes.Buffer
+	err := writeManifestFile(&buf, header, body)
+	if err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
+
+// syntheticManifest builds a manifest of n files, each stored in its own chunk.
+func syntheticManifest(n int) *BinaryManifest {
+	dataList := &FChunkDataList{ChunkLookup: make(map[uuid.UUID]uint32, n)}
+	list := &FFileManifestList{}
+	for i := 0; i < n; i++ {
+		chunk := &Chunk{
+			GUID:       uuid.New(),
+			Hash:       uint64(i) * 7919,
+			Group:      uint8(i % 100),
+			WindowSize: 1 << 20,
+			FileSize:   uint64(1000 + i),
+		}
+		chunk.SHAHash[0] = byte(i)
+		dataList.ChunkLookup[chunk.GUID] = uint32(len(dataList.Chunks))
+		dataList.Chunks = append(dataList.Chunks, chunk)
+
+		file := File{
+			FileName:    "Content/Paks/dir" + strconv.Itoa(i%50) + "/file_" + strconv.Itoa(i) + ".pak",
+			InstallTags: []string{"tag" + strconv.Itoa(i%3)},
+			ChunkParts:  []ChunkPart{{ParentGUID: chunk.GUID, Chunk: chunk, Size: uint32(500 + i)}},
+		}
+		if i%10 == 0 {
+			file.FileName += "ü"
+		}
+		if i%7 == 0 {
+			file.FileMetaFlags = EFileMetaFlagsUnixExecutable
+		}
+		list.FileManifestList = append(list.FileManifestList, file)
+	}
+
+	fields := &FCustomFields{}
+	fields.Set(CustomFieldBaseURL, "http://cdn.example.com/Builds")
+	fields.Set(CustomFieldBuildLabel, "Live")
+
+	return &BinaryManifest{
+		Header: &FManifestHeader{Version: EFeatureLevelLatest},
+		Metadata: &FManifestMeta{
+			DataVersion:  1,
+			FeatureLevel: EFeatureLevelLatest,
+			AppName:      "Synthetic",
+			BuildVersion: "++Synthetic+Release-1.0",
+			PrereqIds:    []string{"prereq"},
+			BuildId:      "build",
+		},
+		ChunkDataList:    dataList,
+		FileManifestList: list,
+		CustomFields:     fields,
+	}
+}
+
+func TestParseManifest(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		manifest := syntheticManifest(300)
+		data := encodeTestManifest(manifest, compress)
+
+		parsed, err := ParseManifest(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("compress %v: %v", compress, err)
+		}
+		if !reflect.DeepEqual(manifest, parsed) {
+			t.Errorf("compress %v: parsed manifest doesn't match the encoded one", compress)
+		}
+	}
+}
+
+// BenchmarkParseManifest parses a compressed manifest of 50k files, run it with -benchmem
+// to compare allocations.
+func BenchmarkParseManifest(b *testing.B) {
+	data := encodeTestManifest(syntheticManifest(50000), true)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := ParseManifest(bytes.NewReader(data))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
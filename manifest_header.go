@@ -6,8 +6,12 @@ import (
 	"io"
 
 	"github.com/er-azh/egmanifest/binreader"
+	"github.com/er-azh/egmanifest/binwriter"
 )
 
+// manifestHeaderSize is the size of the header written by WriteHeader, including the magic.
+const manifestHeaderSize = 41
+
 type FManifestHeader struct {
 	HeaderSize           int32
 	DataSizeUncompressed int32
@@ -57,11 +61,10 @@ func ParseHeader(f io.ReadSeeker) (*FManifestHeader, error) {
 		return nil, err
 	}
 
-	_, shaHash, err := reader.ReadBytes(20)
+	_, err = io.ReadFull(reader, header.SHAHash[:])
 	if err != nil {
 		return nil, err
 	}
-	copy(header.SHAHash[:], shaHash)
 
 	header.StoredAs, err = reader.ReadUint8()
 	if err != nil {
@@ -74,5 +77,27 @@ func ParseHeader(f io.ReadSeeker) (*FManifestHeader, error) {
 
 	header.Version = EFeatureLevel(version)
 
+	// leave f right after the header for the caller
+	err = reader.Sync()
+	if err != nil {
+		return nil, err
+	}
 	return &header, nil
 }
+
+// WriteHeader writes the magic and header, the inverse of the magic check and ParseHeader.
+func WriteHeader(w io.Writer, header *FManifestHeader) error {
+	// writes to a Buffer can't fail
+	var buf binwriter.Buffer
+	writer := binwriter.NewWriter(&buf, binary.LittleEndian)
+	writer.WriteUint32(BinaryManifestMagic)
+	writer.WriteInt32(header.HeaderSize)
+	writer.WriteInt32(header.DataSizeUncompressed)
+	writer.WriteInt32(header.DataSizeCompressed)
+	writer.WriteBytes(header.SHAHash[:])
+	writer.WriteUint8(header.StoredAs)
+	writer.WriteInt32(int32(header.Version))
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 
 	"github.com/er-azh/egmanifest/binreader"
+	"github.com/er-azh/egmanifest/binwriter"
 )
 
 type FManifestMeta struct {
@@ -130,5 +131,44 @@ func ReadFManifestMeta(f io.ReadSeeker) (*FManifestMeta, error) {
 		}
 	}
 
+	// leave f right after the section for the caller
+	err = reader.Sync()
+	if err != nil {
+		return nil, err
+	}
 	return &meta, nil
 }
+
+// WriteFManifestMeta writes meta as a meta section, the inverse of ReadFManifestMeta,
+// and sets meta.DataSize to the size that was written.
+func WriteFManifestMeta(w io.Writer, meta *FManifestMeta) error {
+	// writes to a Buffer can't fail
+	var buf binwriter.Buffer
+	writer := binwriter.NewWriter(&buf, binary.LittleEndian)
+
+	section, _ := writer.BeginSection()
+	writer.WriteUint8(meta.DataVersion)
+	writer.WriteInt32(int32(meta.FeatureLevel))
+	writer.WriteBool(meta.IsFileData)
+	writer.WriteInt32(meta.AppID)
+	writer.WriteFString(meta.AppName)
+	writer.WriteFString(meta.BuildVersion)
+	writer.WriteFString(meta.LaunchExe)
+	writer.WriteFString(meta.LaunchCommand)
+	writer.WriteFStringArray(meta.PrereqIds)
+	writer.WriteFString(meta.PrereqName)
+	writer.WriteFString(meta.PrereqPath)
+	writer.WriteFString(meta.PrereqArgs)
+	if meta.DataVersion >= 1 {
+		writer.WriteFString(meta.BuildId)
+	}
+
+	size, err := writer.EndSection(section)
+	if err != nil {
+		return err
+	}
+	meta.DataSize = size
+
+	_, err = w.Write(buf.Bytes())
+	return err
+}
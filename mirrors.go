@@ -0,0 +1,182 @@
+package egmanifest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNoMirrors = errors.New("no mirrors configured")
+)
+
+type Mirror struct {
+	// example: http://epicgames-download1.akamaized.net/Builds/Fortnite/CloudDir/ChunksV4
+	ChunksDir string
+	// mirrors with a higher weight are tried first, equal weights keep their order
+	Weight int
+	// see HTTPChunkSource.Query
+	Query string
+}
+
+type MirrorStats struct {
+	Mirror
+	Requests       int
+	Failures       int
+	HashMismatches int
+	BytesReceived  uint64
+
+	Healthy             bool
+	ConsecutiveFailures int
+	LastFailure         time.Time
+	LastError           error
+}
+
+// MirrorSource is a ChunkSource that fetches chunks from several CloudDir mirrors,
+// falling back to the next one when a mirror fails or serves bad data.
+type MirrorSource struct {
+	Client *http.Client
+	// see HTTPChunkSource.Manifest
+	Manifest *BinaryManifest
+	// consecutive failures after which a mirror is only used as a last resort
+	FailureThreshold int
+	// how long an unhealthy mirror is avoided before it gets another chance
+	Cooldown time.Duration
+	// decode each chunk and check it against Chunk.SHAHash before accepting it
+	VerifyHashes bool
+
+	mu    sync.Mutex
+	stats []MirrorStats
+}
+
+func NewMirrorSource(mirrors []Mirror) *MirrorSource {
+	s := &MirrorSource{
+		FailureThreshold: 3,
+		Cooldown:         time.Minute,
+		VerifyHashes:     true,
+		stats:            make([]MirrorStats, len(mirrors)),
+	}
+	for idx, mirror := range mirrors {
+		s.stats[idx] = MirrorStats{Mirror: mirror, Healthy: true}
+	}
+	return s
+}
+
+// Stats returns a snapshot of the per-mirror statistics.
+func (s *MirrorSource) Stats() []MirrorStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]MirrorStats, len(s.stats))
+	copy(out, s.stats)
+	return out
+}
+
+// candidates returns the mirror indexes in the order they should be tried.
+func (s *MirrorSource) candidates() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	order := make([]int, len(s.stats))
+	usable := make([]bool, len(s.stats))
+	for idx := range s.stats {
+		order[idx] = idx
+		stats := &s.stats[idx]
+		usable[idx] = stats.Healthy || now.Sub(stats.LastFailure) >= s.Cooldown
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := order[i], order[j]
+		if usable[a] != usable[b] {
+			return usable[a]
+		}
+		return s.stats[a].Weight > s.stats[b].Weight
+	})
+	return order
+}
+
+func (s *MirrorSource) record(idx int, size int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := &s.stats[idx]
+	stats.Requests++
+	if err == nil {
+		stats.BytesReceived += uint64(size)
+		stats.ConsecutiveFailures = 0
+		stats.Healthy = true
+		return
+	}
+
+	stats.Failures++
+	if errors.Is(err, ErrChunkHashMismatch) || errors.Is(err, ErrChunkSizeMismatch) {
+		stats.HashMismatches++
+	}
+	stats.ConsecutiveFailures++
+	stats.LastFailure = time.Now()
+	stats.LastError = err
+	if stats.ConsecutiveFailures >= s.FailureThreshold {
+		stats.Healthy = false
+	}
+}
+
+func (s *MirrorSource) FetchChunk(ctx context.Context, c *Chunk) ([]byte, error) {
+	order := s.candidates()
+	if len(order) == 0 {
+		return nil, ErrNoMirrors
+	}
+
+	var lastErr error
+	for _, idx := range order {
+		raw, err := s.fetchFrom(ctx, idx, c)
+		if ctx.Err() != nil {
+			// a cancelled request says nothing about the mirror
+			return nil, ctx.Err()
+		}
+		s.record(idx, len(raw), err)
+		if err == nil {
+			return raw, nil
+		}
+		lastErr = err
+	}
+	return nil, fmt.Errorf("all %d mirrors failed, last error: %w", len(order), lastErr)
+}
+
+func (s *MirrorSource) fetchFrom(ctx context.Context, idx int, c *Chunk) ([]byte, error) {
+	s.mu.Lock()
+	src := HTTPChunkSource{ChunksDir: s.stats[idx].ChunksDir, Client: s.Client, Manifest: s.Manifest, Query: s.stats[idx].Query}
+	s.mu.Unlock()
+
+	raw, err := src.FetchChunk(ctx, c)
+	if err != nil {
+		return nil, fmt.Errorf("mirror %s: %w", src.ChunksDir, err)
+	}
+	if c.FileSize != 0 && uint64(len(raw)) != c.FileSize {
+		return nil, fmt.Errorf("mirror %s: chunk %s: %w", src.ChunksDir, c.GUID, ErrChunkSizeMismatch)
+	}
+	if s.VerifyHashes {
+		_, err = DecodeChunk(raw, c)
+		if err != nil {
+			return nil, fmt.Errorf("mirror %s: %w", src.ChunksDir, err)
+		}
+	}
+	return raw, nil
+}
+
+// NewManifestMirrorSource returns a MirrorSource over the CloudDirs the manifest advertises
+// in its BaseUrl custom field, or ErrNoMirrors if it doesn't list any.
+func NewManifestMirrorSource(manifest *BinaryManifest) (*MirrorSource, error) {
+	mirrors := manifest.Mirrors()
+	if len(mirrors) == 0 {
+		return nil, ErrNoMirrors
+	}
+
+	s := NewMirrorSource(mirrors)
+	s.Manifest = manifest
+	return s, nil
+}
@@ -0,0 +1,114 @@
+package egmanifest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// mirrorServer serves the chunks of manifest from src, or the chunk of the other GUID if corrupt is set,
+// and counts the requests it gets.
+type mirrorServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests int
+}
+
+func newMirrorServer(t *testing.T, manifest *BinaryManifest, src memSource, status int, corrupt bool) *mirrorServer {
+	chunks := manifest.ChunkDataList.Chunks
+	files := map[string][]byte{}
+	for idx, chunk := range chunks {
+		served := chunk
+		if corrupt {
+			served = chunks[(idx+1)%len(chunks)]
+		}
+		files["/"+manifest.DataPath(chunk)] = src[served.GUID]
+	}
+
+	s := &mirrorServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests++
+		s.mu.Unlock()
+
+		data, ok := files[r.URL.Path]
+		if status != http.StatusOK || !ok {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		w.Write(data)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestMirrorSourceFailover(t *testing.T) {
+	manifest, src := testManifest()
+	dead := newMirrorServer(t, manifest, src, http.StatusInternalServerError, false)
+	corrupt := newMirrorServer(t, manifest, src, http.StatusOK, true)
+	live := newMirrorServer(t, manifest, src, http.StatusOK, false)
+
+	s := NewMirrorSource([]Mirror{
+		{ChunksDir: live.URL, Weight: 1},
+		{ChunksDir: dead.URL, Weight: 3},
+		{ChunksDir: corrupt.URL, Weight: 2},
+	})
+	s.Manifest = manifest
+	s.FailureThreshold = 2
+
+	chunks := manifest.ChunkDataList.Chunks
+	var received uint64
+	for _, chunk := range []*Chunk{chunks[0], chunks[1], chunks[0]} {
+		raw, err := s.FetchChunk(context.Background(), chunk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := DecodeChunk(raw, chunk); err != nil {
+			t.Errorf("chunk %s: %v", chunk.GUID, err)
+		}
+		received += uint64(len(raw))
+	}
+
+	// the broken mirrors are tried first until they reach the failure threshold
+	if dead.requests != 2 || corrupt.requests != 2 || live.requests != 3 {
+		t.Errorf("requests: dead %d, corrupt %d, live %d, want 2, 2 and 3", dead.requests, corrupt.requests, live.requests)
+	}
+
+	stats := s.Stats()
+	want := []MirrorStats{
+		{Requests: 3, BytesReceived: received, Healthy: true},
+		{Requests: 2, Failures: 2, ConsecutiveFailures: 2},
+		{Requests: 2, Failures: 2, HashMismatches: 2, ConsecutiveFailures: 2},
+	}
+	for idx, got := range stats {
+		w := want[idx]
+		if got.Requests != w.Requests || got.Failures != w.Failures || got.HashMismatches != w.HashMismatches ||
+			got.BytesReceived != w.BytesReceived || got.Healthy != w.Healthy || got.ConsecutiveFailures != w.ConsecutiveFailures {
+			t.Errorf("%s: requests %d, failures %d, hash mismatches %d, bytes %d, healthy %v, consecutive failures %d; want %d, %d, %d, %d, %v, %d",
+				got.ChunksDir, got.Requests, got.Failures, got.HashMismatches, got.BytesReceived, got.Healthy, got.ConsecutiveFailures,
+				w.Requests, w.Failures, w.HashMismatches, w.BytesReceived, w.Healthy, w.ConsecutiveFailures)
+		}
+		if got.Failures != 0 && (got.LastError == nil || got.LastFailure.IsZero()) {
+			t.Errorf("%s: last failure wasn't recorded", got.ChunksDir)
+		}
+	}
+}
+
+func TestMirrorSourceAllFail(t *testing.T) {
+	manifest, src := testManifest()
+	dead := newMirrorServer(t, manifest, src, http.StatusNotFound, false)
+
+	s := NewMirrorSource([]Mirror{{ChunksDir: dead.URL}})
+	s.Manifest = manifest
+	_, err := s.FetchChunk(context.Background(), manifest.ChunkDataList.Chunks[0])
+	if err == nil {
+		t.Error("FetchChunk succeeded without a working mirror")
+	}
+
+	_, err = NewMirrorSource(nil).FetchChunk(context.Background(), manifest.ChunkDataList.Chunks[0])
+	if err != ErrNoMirrors {
+		t.Errorf("FetchChunk without mirrors = %v, want ErrNoMirrors", err)
+	}
+}
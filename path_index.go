@@ -0,0 +1,165 @@
+package egmanifest
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// PathIndex looks up the files of a manifest by path. Paths are relative to the install
+// directory and use forward slashes, like File.FileName.
+type PathIndex struct {
+	byName map[string]*File
+	// files by strings.ToLower of their name, in manifest order
+	byFolded map[string][]*File
+	// files sorted by name, for prefix queries and directory listings
+	sorted []*File
+}
+
+// PathEntry is a direct child of a directory, either a file or a sub directory.
+type PathEntry struct {
+	Name string
+	// nil for directories
+	File *File
+}
+
+func (e PathEntry) IsDir() bool {
+	return e.File == nil
+}
+
+// NewPathIndex indexes files. When several files have the same name the first one wins.
+func NewPathIndex(files []*File) *PathIndex {
+	idx := &PathIndex{
+		byName:   make(map[string]*File, len(files)),
+		byFolded: make(map[string][]*File, len(files)),
+		sorted:   make([]*File, 0, len(files)),
+	}
+
+	for _, file := range files {
+		if _, ok := idx.byName[file.FileName]; ok {
+			continue
+		}
+		idx.byName[file.FileName] = file
+		folded := strings.ToLower(file.FileName)
+		idx.byFolded[folded] = append(idx.byFolded[folded], file)
+		idx.sorted = append(idx.sorted, file)
+	}
+
+	sort.Slice(idx.sorted, func(i, j int) bool {
+		return idx.sorted[i].FileName < idx.sorted[j].FileName
+	})
+	return idx
+}
+
+// Index builds a PathIndex over the files of the list.
+func (l *FFileManifestList) Index() *PathIndex {
+	return NewPathIndex(l.Files())
+}
+
+// Len returns the number of indexed files.
+func (idx *PathIndex) Len() int {
+	return len(idx.sorted)
+}
+
+// Lookup returns the file with exactly the given name.
+func (idx *PathIndex) Lookup(name string) (*File, bool) {
+	file, ok := idx.byName[name]
+	return file, ok
+}
+
+// LookupFold returns the file whose name matches name case-insensitively, as Windows would.
+// An exact match is preferred, otherwise the first match in manifest order is returned.
+func (idx *PathIndex) LookupFold(name string) (*File, bool) {
+	if file, ok := idx.byName[name]; ok {
+		return file, true
+	}
+
+	matches := idx.byFolded[strings.ToLower(name)]
+	if len(matches) == 0 {
+		return nil, false
+	}
+	return matches[0], true
+}
+
+// search returns the index of the first file in sorted whose name is >= name.
+func (idx *PathIndex) search(name string) int {
+	return sort.Search(len(idx.sorted), func(i int) bool {
+		return idx.sorted[i].FileName >= name
+	})
+}
+
+// Prefix returns the files whose name starts with prefix, sorted by name.
+func (idx *PathIndex) Prefix(prefix string) []*File {
+	start := idx.search(prefix)
+	end := start
+	for end < len(idx.sorted) && strings.HasPrefix(idx.sorted[end].FileName, prefix) {
+		end++
+	}
+
+	files := make([]*File, end-start)
+	copy(files, idx.sorted[start:end])
+	return files
+}
+
+// ReadDir lists the direct children of dir sorted by name, "" or "." is the root.
+// It returns nil if there are no files below dir.
+func (idx *PathIndex) ReadDir(dir string) []PathEntry {
+	dir = strings.Trim(dir, "/")
+	prefix := ""
+	if dir != "" && dir != "." {
+		prefix = dir + "/"
+	}
+
+	var entries []PathEntry
+	i := idx.search(prefix)
+	for i < len(idx.sorted) && strings.HasPrefix(idx.sorted[i].FileName, prefix) {
+		file := idx.sorted[i]
+		rest := file.FileName[len(prefix):]
+
+		slash := strings.IndexByte(rest, '/')
+		if slash == -1 {
+			entries = append(entries, PathEntry{Name: rest, File: file})
+			i++
+			continue
+		}
+
+		// skip everything below the sub directory, '0' sorts right after '/'
+		sub := rest[:slash]
+		entries = append(entries, PathEntry{Name: sub})
+		i = idx.search(prefix + sub + "0")
+	}
+
+	// sub directories were found in the order of the names below them, not their own
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+	return entries
+}
+
+// Glob returns the files whose name matches pattern, sorted by name. The pattern
+// syntax is the one of path.Match, so '*' doesn't match across directories.
+func (idx *PathIndex) Glob(pattern string) ([]*File, error) {
+	// check the pattern even if nothing gets matched against it
+	_, err := path.Match(pattern, "")
+	if err != nil {
+		return nil, err
+	}
+
+	// only names starting with the literal part of the pattern can match
+	literal := pattern
+	if meta := strings.IndexAny(pattern, `*?[\`); meta != -1 {
+		literal = pattern[:meta]
+	}
+
+	var files []*File
+	for i := idx.search(literal); i < len(idx.sorted); i++ {
+		file := idx.sorted[i]
+		if !strings.HasPrefix(file.FileName, literal) {
+			break
+		}
+		if ok, _ := path.Match(pattern, file.FileName); ok {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
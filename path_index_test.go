@@ -0,0 +1,101 @@
+package egmanifest
+
+import (
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func pathIndexFiles() []*File {
+	var files []*File
+	for _, name := range []string{"dir/sub/d.txt", "a.txt", "dir/b.txt", "dirfile", "dir-x/e.txt", "dir/sub/c.txt", "a.txt"} {
+		files = append(files, &File{FileName: name})
+	}
+	return files
+}
+
+func TestPathIndexReadDir(t *testing.T) {
+	idx := NewPathIndex(pathIndexFiles())
+	if idx.Len() != 6 {
+		t.Errorf("Len = %d, want 6 without the duplicate", idx.Len())
+	}
+
+	type entry struct {
+		name  string
+		isDir bool
+	}
+	tests := []struct {
+		dir  string
+		want []entry
+	}{
+		// "dir-x" sorts before "dir/" but the directory listing is by name
+		{"", []entry{{"a.txt", false}, {"dir", true}, {"dir-x", true}, {"dirfile", false}}},
+		{".", []entry{{"a.txt", false}, {"dir", true}, {"dir-x", true}, {"dirfile", false}}},
+		{"dir", []entry{{"b.txt", false}, {"sub", true}}},
+		{"/dir/", []entry{{"b.txt", false}, {"sub", true}}},
+		{"dir/sub", []entry{{"c.txt", false}, {"d.txt", false}}},
+		{"missing", nil},
+		// prefixes of names that aren't directories
+		{"di", nil},
+		{"dir/b.txt", nil},
+		{"dirfile", nil},
+	}
+	for _, test := range tests {
+		var got []entry
+		for _, e := range idx.ReadDir(test.dir) {
+			if !e.IsDir() && e.File.FileName != path.Join(strings.Trim(test.dir, "/"), e.Name) {
+				t.Errorf("ReadDir(%q): entry %s has file %s", test.dir, e.Name, e.File.FileName)
+			}
+			got = append(got, entry{e.Name, e.IsDir()})
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ReadDir(%q) = %v, want %v", test.dir, got, test.want)
+		}
+	}
+}
+
+func TestPathIndexLookup(t *testing.T) {
+	files := pathIndexFiles()
+	idx := NewPathIndex(files)
+
+	if file, ok := idx.Lookup("a.txt"); !ok || file != files[1] {
+		t.Errorf("Lookup(a.txt) didn't return the first of the duplicates")
+	}
+	for _, name := range []string{"dir", "dir/", "dir/sub", "di", "dir/b", "A.TXT", ""} {
+		if file, ok := idx.Lookup(name); ok {
+			t.Errorf("Lookup(%q) = %s, want no file", name, file.FileName)
+		}
+	}
+	if file, ok := idx.LookupFold("DIR/B.TXT"); !ok || file.FileName != "dir/b.txt" {
+		t.Errorf("LookupFold(DIR/B.TXT) = %v, %v", file, ok)
+	}
+	if _, ok := idx.LookupFold("DIR"); ok {
+		t.Errorf("LookupFold(DIR) found a directory")
+	}
+
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"dir/", []string{"dir/b.txt", "dir/sub/c.txt", "dir/sub/d.txt"}},
+		{"dir", []string{"dir-x/e.txt", "dir/b.txt", "dir/sub/c.txt", "dir/sub/d.txt", "dirfile"}},
+		{"missing", []string{}},
+	}
+	for _, test := range tests {
+		if names := fileNames(idx.Prefix(test.prefix)); !reflect.DeepEqual(names, test.want) {
+			t.Errorf("Prefix(%q) = %v, want %v", test.prefix, names, test.want)
+		}
+	}
+
+	matches, err := idx.Glob("dir/*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := fileNames(matches); !reflect.DeepEqual(names, []string{"dir/b.txt"}) {
+		t.Errorf("Glob(dir/*.txt) = %v, want [dir/b.txt]", names)
+	}
+	if _, err := idx.Glob("dir/["); err == nil {
+		t.Errorf("Glob accepted a bad pattern")
+	}
+}
@@ -0,0 +1,259 @@
+package egmanifest
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// ManifestSections selects the sections ParseManifestSections decodes.
+type ManifestSections uint8
+
+const (
+	SectionMeta ManifestSections = 1 << iota
+	SectionChunkDataList
+	// the file manifest list needs the chunk data list, which is decoded with it
+	SectionFileManifestList
+	SectionCustomFields
+
+	SectionsAll = SectionMeta | SectionChunkDataList | SectionFileManifestList | SectionCustomFields
+)
+
+// ParseManifestMeta decodes only the header and the meta section of a manifest,
+// which is enough for the app name, build version and build id.
+func ParseManifestMeta(f io.ReadSeeker) (*BinaryManifest, error) {
+	return ParseManifestSections(f, SectionMeta)
+}
+
+// ParseManifestSections parses a manifest like ParseManifest, but only decodes the selected sections
+// and leaves the others nil. Compressed manifests are decompressed as a stream that stops after the
+// last selected section, and sections before it are skipped without decoding them.
+// The decompressed size is checked like ParseManifest does as far as the stream is read,
+// so it's only fully checked when the custom fields are selected.
+func ParseManifestSections(f io.ReadSeeker, sections ManifestSections) (*BinaryManifest, error) {
+	var manifest BinaryManifest
+	var err error
+	manifest.Header, err = readManifestHeader(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var reader io.Reader = f
+	var counter *countingReader
+	var size int64
+	if (manifest.Header.StoredAs & StoredCompressed) != 0 {
+		zreader, err := zlib.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer zreader.Close()
+		counter = &countingReader{r: zreader}
+		reader = counter
+		size = int64(manifest.Header.DataSizeUncompressed)
+	} else {
+		size, err = remainingSize(f)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = walkSections(reader, size, sections, &manifest, func(r io.ReadSeeker, dataList *FChunkDataList) (uint32, error) {
+		manifest.FileManifestList, err = ReadFileManifestList(r, dataList)
+		if err != nil {
+			return 0, err
+		}
+		return manifest.FileManifestList.DataSize, nil
+	})
+	if counter != nil {
+		if err == nil && sections&SectionCustomFields != 0 {
+			// the custom fields are the last section, anything after them is too much data
+			_, err = io.Copy(ioutil.Discard, counter)
+		}
+		// a stream that ended early makes the sections fail, report why
+		if counter.eof && counter.n != size {
+			return nil, errDataSize(manifest.Header, counter.n)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+// countingReader counts the bytes read from r and whether it reached its end.
+type countingReader struct {
+	r   io.Reader
+	n   int64
+	eof bool
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	if err == io.EOF {
+		r.eof = true
+	}
+	return n, err
+}
+
+// walkSections decodes the selected sections of manifest data into manifest, r starts at the meta
+// section and nothing after the last selected section is read. The file manifest list is decoded
+// by readFiles, which returns its DataSize. Sections are decoded in place if r is an io.ReadSeeker,
+// otherwise every selected section is buffered before decoding it.
+// size is the size of the data, sections that don't fit in what's left of it are an error.
+func walkSections(r io.Reader, size int64, sections ManifestSections, manifest *BinaryManifest, readFiles func(r io.ReadSeeker, dataList *FChunkDataList) (uint32, error)) error {
+	if sections&SectionFileManifestList != 0 {
+		sections |= SectionChunkDataList
+	}
+	seeker, canSeek := r.(io.ReadSeeker)
+
+	for section := SectionMeta; section <= SectionCustomFields && sections >= section; section <<= 1 {
+		if sections&section == 0 {
+			skipped, err := skipSection(r, size)
+			if err != nil {
+				return fmt.Errorf("skipping %s: %w", section, err)
+			}
+			size -= int64(skipped)
+			continue
+		}
+
+		var reader io.ReadSeeker
+		var start int64
+		var err error
+		if canSeek {
+			reader = seeker
+			start, err = seeker.Seek(0, io.SeekCurrent)
+			if err != nil {
+				return err
+			}
+		} else {
+			data, err := readSection(r, size)
+			if err != nil {
+				return fmt.Errorf("reading %s: %w", section, err)
+			}
+			reader = bytes.NewReader(data)
+		}
+
+		var sectionSize uint32
+		switch section {
+		case SectionMeta:
+			manifest.Metadata, err = ReadFManifestMeta(reader)
+			if err == nil {
+				sectionSize = manifest.Metadata.DataSize
+			}
+		case SectionChunkDataList:
+			manifest.ChunkDataList, err = ReadChunkDataList(reader)
+			if err == nil {
+				sectionSize = manifest.ChunkDataList.DataSize
+			}
+		case SectionFileManifestList:
+			sectionSize, err = readFiles(reader, manifest.ChunkDataList)
+		case SectionCustomFields:
+			manifest.CustomFields, err = ReadCustomFields(reader)
+			if err == nil {
+				sectionSize = manifest.CustomFields.DataSize
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if int64(sectionSize) > size {
+			return fmt.Errorf("reading %s: %w", section, errSectionSize(sectionSize, size))
+		}
+		size -= int64(sectionSize)
+
+		// the decoders can stop before the end of a section written by a newer version
+		if canSeek {
+			_, err = seeker.Seek(start+int64(sectionSize), io.SeekStart)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s ManifestSections) String() string {
+	switch s {
+	case SectionMeta:
+		return "meta"
+	case SectionChunkDataList:
+		return "chunk data list"
+	case SectionFileManifestList:
+		return "file manifest list"
+	case SectionCustomFields:
+		return "custom fields"
+	}
+	return fmt.Sprintf("sections 0x%02X", uint8(s))
+}
+
+// remainingSize returns the number of bytes between the position of s and its end.
+func remainingSize(s io.Seeker) (int64, error) {
+	pos, err := s.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	end, err := s.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	_, err = s.Seek(pos, io.SeekStart)
+	if err != nil {
+		return 0, err
+	}
+	return end - pos, nil
+}
+
+func errSectionSize(size uint32, left int64) error {
+	return fmt.Errorf("section size %d is larger than the %d bytes left in the manifest", size, left)
+}
+
+// readSectionSize reads the DataSize that starts every section, which includes itself.
+// Sections larger than limit are an error.
+func readSectionSize(r io.Reader, limit int64) (uint32, error) {
+	var buf [4]byte
+	_, err := io.ReadFull(r, buf[:])
+	if err != nil {
+		return 0, err
+	}
+
+	size := binary.LittleEndian.Uint32(buf[:])
+	if size < 4 {
+		return 0, fmt.Errorf("invalid section size %d", size)
+	}
+	if int64(size) > limit {
+		return 0, errSectionSize(size, limit)
+	}
+	return size, nil
+}
+
+// readSection reads a whole section including its DataSize, see readSectionSize.
+func readSection(r io.Reader, limit int64) ([]byte, error) {
+	size, err := readSectionSize(r, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, size)
+	binary.LittleEndian.PutUint32(data, size)
+	_, err = io.ReadFull(r, data[4:])
+	return data, err
+}
+
+// skipSection skips a whole section and returns its size, see readSectionSize.
+func skipSection(r io.Reader, limit int64) (uint32, error) {
+	size, err := readSectionSize(r, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	if seeker, ok := r.(io.Seeker); ok {
+		_, err = seeker.Seek(int64(size)-4, io.SeekCurrent)
+		return size, err
+	}
+	_, err = io.CopyN(ioutil.Discard, r, int64(size)-4)
+	return size, err
+}
@@ -0,0 +1,86 @@
+package egmanifest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseManifestSections(t *testing.T) {
+	manifest, _ := testManifest()
+	for _, compress := range []bool{false, true} {
+		data := encodeTestManifest(manifest, compress)
+
+		meta, err := ParseManifestSections(bytes.NewReader(data), SectionMeta)
+		if err != nil {
+			t.Fatalf("compress %v: %v", compress, err)
+		}
+		if !reflect.DeepEqual(meta.Metadata, manifest.Metadata) || meta.ChunkDataList != nil || meta.CustomFields != nil {
+			t.Errorf("compress %v: meta only parse decoded the wrong sections", compress)
+		}
+
+		fields, err := ParseManifestSections(bytes.NewReader(data), SectionCustomFields)
+		if err != nil {
+			t.Fatalf("compress %v: %v", compress, err)
+		}
+		if !reflect.DeepEqual(fields.CustomFields, manifest.CustomFields) || fields.Metadata != nil {
+			t.Errorf("compress %v: custom fields only parse decoded the wrong sections", compress)
+		}
+	}
+}
+
+func TestParseManifestSectionsSize(t *testing.T) {
+	manifest, _ := testManifest()
+	body := encodeTestBody(manifest)
+
+	// a chunk data list claiming to be far larger than the manifest
+	listStart := manifest.Metadata.DataSize
+	binary.LittleEndian.PutUint32(body[listStart:], 0x7FFFFFFF)
+
+	for _, compress := range []bool{false, true} {
+		data := encodeTestFile(manifest.Header, body, compress)
+		for _, sections := range []ManifestSections{SectionChunkDataList, SectionCustomFields, SectionsAll} {
+			_, err := ParseManifestSections(bytes.NewReader(data), sections)
+			if err == nil {
+				t.Errorf("compress %v, %s: oversized section was accepted", compress, sections)
+			}
+		}
+	}
+}
+
+func TestParseManifestSectionsDataSize(t *testing.T) {
+	manifest, _ := testManifest()
+	body := encodeTestBody(manifest)
+
+	tests := []struct {
+		name    string
+		payload []byte
+	}{
+		{"truncated", body[:len(body)-10]},
+		{"oversized", append(append([]byte(nil), body...), make([]byte, 10)...)},
+	}
+	for _, test := range tests {
+		data := encodeTestFile(manifest.Header, test.payload, true)
+		// the header claims the size of the real body
+		binary.LittleEndian.PutUint32(data[8:], uint32(len(body)))
+
+		_, err := ParseManifest(bytes.NewReader(data))
+		if !errors.Is(err, ErrDataSizeMismatch) {
+			t.Errorf("%s: ParseManifest = %v, want ErrDataSizeMismatch", test.name, err)
+		}
+		for _, sections := range []ManifestSections{SectionCustomFields, SectionsAll} {
+			_, err := ParseManifestSections(bytes.NewReader(data), sections)
+			if !errors.Is(err, ErrDataSizeMismatch) {
+				t.Errorf("%s, %s: ParseManifestSections = %v, want ErrDataSizeMismatch", test.name, sections, err)
+			}
+		}
+
+		// the meta section is complete in both and nothing after it is read
+		_, err = ParseManifestSections(bytes.NewReader(data), SectionMeta)
+		if err != nil {
+			t.Errorf("%s: meta only: %v", test.name, err)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package egmanifest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+type servedManifest struct {
+	raw     []byte
+	etag    string
+	modTime time.Time
+}
+
+// CloudDirServer is an http.Handler serving manifests and chunks at the paths clients expect under
+// a CloudDir, e.g. "Fortnite.manifest" and "ChunksV4/01/0123456789ABCDEF_0123456789ABCDEF0123456789ABCDEF.chunk".
+// Mount it with http.StripPrefix to serve it below a path.
+type CloudDirServer struct {
+	src ChunkSource
+
+	mu        sync.RWMutex
+	manifests map[string]*servedManifest
+	chunks    map[string]*Chunk
+}
+
+// NewCloudDirServer returns a server that reads chunks from src, usually a *ChunkStore.
+func NewCloudDirServer(src ChunkSource) *CloudDirServer {
+	return &CloudDirServer{
+		src:       src,
+		manifests: map[string]*servedManifest{},
+		chunks:    map[string]*Chunk{},
+	}
+}
+
+// AddManifest serves the raw manifest file under name and makes its chunks available.
+func (s *CloudDirServer) AddManifest(name string, raw []byte) (*BinaryManifest, error) {
+	manifest, err := ParseManifest(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.manifests[strings.TrimPrefix(name, "/")] = &servedManifest{
+		raw:     raw,
+		etag:    fmt.Sprintf(`"%x"`, manifest.Header.SHAHash),
+		modTime: time.Now(),
+	}
+	s.mu.Unlock()
+
+	s.AddBuild(manifest)
+	return manifest, nil
+}
+
+// AddBuild makes the chunks or file data of a manifest available, for generated builds
+// that don't have a manifest file.
+func (s *CloudDirServer) AddBuild(manifest *BinaryManifest) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subDir := manifest.DataSubDir()
+	for _, chunk := range manifest.ChunkDataList.Chunks {
+		s.chunks[subDir+"/"+manifest.DataPath(chunk)] = chunk
+	}
+}
+
+// AddChunks makes chunks available at their paths for a manifest of the given feature level.
+func (s *CloudDirServer) AddChunks(featureLevel EFeatureLevel, chunks []*Chunk) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subDir := featureLevel.ChunkSubDir()
+	for _, chunk := range chunks {
+		s.chunks[subDir+"/"+chunk.PathFor(featureLevel)] = chunk
+	}
+}
+
+func (s *CloudDirServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/")
+
+	s.mu.RLock()
+	manifest, isManifest := s.manifests[path]
+	chunk, isChunk := s.chunks[path]
+	s.mu.RUnlock()
+
+	switch {
+	case isManifest:
+		w.Header().Set("ETag", manifest.etag)
+		w.Header().Set("Content-Type", "application/octet-stream")
+		http.ServeContent(w, r, path, manifest.modTime, bytes.NewReader(manifest.raw))
+	case isChunk:
+		raw, err := s.src.FetchChunk(r.Context(), chunk)
+		if errors.Is(err, ErrChunkNotFound) {
+			http.NotFound(w, r)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		// chunks are content addressed so they never change
+		w.Header().Set("ETag", fmt.Sprintf(`"%016X_%X"`, chunk.Hash, chunk.GUID[:]))
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		w.Header().Set("Content-Type", "application/octet-stream")
+		http.ServeContent(w, r, path, time.Time{}, bytes.NewReader(raw))
+	default:
+		http.NotFound(w, r)
+	}
+}
@@ -0,0 +1,118 @@
+package egmanifest
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func serverGet(t *testing.T, url string, header map[string]string) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range header {
+		req.Header.Set(key, value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, body
+}
+
+func TestCloudDirServer(t *testing.T) {
+	manifest, src := testManifest()
+	raw := encodeTestManifest(manifest, true)
+
+	// a chunk the store lost
+	missing := manifest.ChunkDataList.Chunks[1]
+	store := memSource{}
+	for guid, data := range src {
+		if guid != missing.GUID {
+			store[guid] = data
+		}
+	}
+
+	cloudDir := NewCloudDirServer(store)
+	_, err := cloudDir.AddManifest("Test.manifest", raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(cloudDir)
+	defer server.Close()
+
+	chunk := manifest.ChunkDataList.Chunks[0]
+	chunkURL := server.URL + "/" + manifest.DataSubDir() + "/" + manifest.DataPath(chunk)
+	tests := []struct {
+		name string
+		url  string
+		data []byte
+	}{
+		{"manifest", server.URL + "/Test.manifest", raw},
+		{"chunk", chunkURL, src[chunk.GUID]},
+	}
+	for _, test := range tests {
+		resp, body := serverGet(t, test.url, nil)
+		if resp.StatusCode != http.StatusOK || !bytes.Equal(body, test.data) {
+			t.Errorf("%s: status %d, %d bytes, want 200 and %d bytes", test.name, resp.StatusCode, len(body), len(test.data))
+		}
+		etag := resp.Header.Get("ETag")
+		if etag == "" {
+			t.Fatalf("%s: no ETag", test.name)
+		}
+
+		resp, body = serverGet(t, test.url, map[string]string{"Range": "bytes=10-19"})
+		if resp.StatusCode != http.StatusPartialContent || !bytes.Equal(body, test.data[10:20]) {
+			t.Errorf("%s: range status %d, body %x, want 206 and %x", test.name, resp.StatusCode, body, test.data[10:20])
+		}
+
+		resp, body = serverGet(t, test.url, map[string]string{"If-None-Match": etag})
+		if resp.StatusCode != http.StatusNotModified || len(body) != 0 {
+			t.Errorf("%s: If-None-Match status %d with %d bytes, want 304 and no body", test.name, resp.StatusCode, len(body))
+		}
+		resp, _ = serverGet(t, test.url, map[string]string{"If-None-Match": `"other"`})
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("%s: stale If-None-Match status %d, want 200", test.name, resp.StatusCode)
+		}
+	}
+
+	for _, url := range []string{
+		server.URL + "/Other.manifest",
+		server.URL + "/" + manifest.DataSubDir() + "/" + manifest.DataPath(missing),
+		server.URL + "/" + manifest.DataPath(chunk),
+	} {
+		resp, _ := serverGet(t, url, nil)
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s: status %d, want 404", url, resp.StatusCode)
+		}
+	}
+
+	resp, err := http.Post(chunkURL, "text/plain", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("POST status %d, want 405", resp.StatusCode)
+	}
+
+	// clients find the chunks where they look for them
+	httpSrc := &HTTPChunkSource{ChunksDir: server.URL + "/" + manifest.DataSubDir(), Manifest: manifest}
+	data, err := ReadChunkData(context.Background(), httpSrc, chunk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != int(chunk.WindowSize) {
+		t.Errorf("fetched chunk has %d bytes, want %d", len(data), chunk.WindowSize)
+	}
+}
@@ -0,0 +1,116 @@
+package egmanifest
+
+import (
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+type FileSizeEntry struct {
+	FileName string
+	Size     uint64
+}
+
+type GroupStats struct {
+	ChunkCount   int
+	DownloadSize uint64
+	WindowSize   uint64
+}
+
+type ManifestStats struct {
+	FileCount      int
+	ChunkCount     int
+	ChunkPartCount int
+
+	// sum of the sizes of all the files
+	InstallSize uint64
+	// sum of Chunk.FileSize
+	DownloadSize uint64
+	// sum of Chunk.WindowSize
+	WindowSize uint64
+	// DownloadSize / WindowSize, 0 if the manifest has no chunks
+	CompressionRatio float64
+
+	// number of files that reference each chunk
+	ChunkFileRefs map[uuid.UUID]int
+	// average of ChunkFileRefs over the referenced chunks
+	ChunkReuse float64
+	// chunks that no file references
+	UnreferencedChunks int
+
+	// the biggest files, largest first
+	LargestFiles []FileSizeEntry
+	Groups       map[uint8]GroupStats
+}
+
+// ComputeStats computes size and deduplication statistics of a build.
+// At most largest files are reported in LargestFiles, all of them if largest is negative.
+func ComputeStats(dataList *FChunkDataList, fileList *FFileManifestList, largest int) *ManifestStats {
+	stats := &ManifestStats{
+		FileCount:     len(fileList.FileManifestList),
+		ChunkCount:    len(dataList.Chunks),
+		ChunkFileRefs: map[uuid.UUID]int{},
+		Groups:        map[uint8]GroupStats{},
+	}
+
+	for _, chunk := range dataList.Chunks {
+		stats.DownloadSize += chunk.FileSize
+		stats.WindowSize += uint64(chunk.WindowSize)
+
+		group := stats.Groups[chunk.Group]
+		group.ChunkCount++
+		group.DownloadSize += chunk.FileSize
+		group.WindowSize += uint64(chunk.WindowSize)
+		stats.Groups[chunk.Group] = group
+	}
+	if stats.WindowSize != 0 {
+		stats.CompressionRatio = float64(stats.DownloadSize) / float64(stats.WindowSize)
+	}
+
+	sizes := make([]FileSizeEntry, 0, len(fileList.FileManifestList))
+	for idx := range fileList.FileManifestList {
+		file := &fileList.FileManifestList[idx]
+		size := file.Size()
+		stats.InstallSize += size
+		stats.ChunkPartCount += len(file.ChunkParts)
+		sizes = append(sizes, FileSizeEntry{FileName: file.FileName, Size: size})
+
+		// count each file once per chunk, even if it uses several parts of it
+		seen := map[uuid.UUID]struct{}{}
+		for _, part := range file.ChunkParts {
+			if _, ok := seen[part.ParentGUID]; ok {
+				continue
+			}
+			seen[part.ParentGUID] = struct{}{}
+			stats.ChunkFileRefs[part.ParentGUID]++
+		}
+	}
+
+	var totalRefs int
+	for _, refs := range stats.ChunkFileRefs {
+		totalRefs += refs
+	}
+	if len(stats.ChunkFileRefs) != 0 {
+		stats.ChunkReuse = float64(totalRefs) / float64(len(stats.ChunkFileRefs))
+	}
+	for _, chunk := range dataList.Chunks {
+		if _, ok := stats.ChunkFileRefs[chunk.GUID]; !ok {
+			stats.UnreferencedChunks++
+		}
+	}
+
+	sort.SliceStable(sizes, func(i, j int) bool {
+		return sizes[i].Size > sizes[j].Size
+	})
+	if largest >= 0 && largest < len(sizes) {
+		sizes = sizes[:largest]
+	}
+	stats.LargestFiles = sizes
+
+	return stats
+}
+
+// Stats computes size and deduplication statistics of the manifest, see ComputeStats.
+func (m *BinaryManifest) Stats(largest int) *ManifestStats {
+	return ComputeStats(m.ChunkDataList, m.FileManifestList, largest)
+}
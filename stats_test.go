@@ -0,0 +1,82 @@
+package egmanifest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// statsManifest builds a manifest with known sizes: four files, two of them sharing chunks,
+// and a chunk that nothing references.
+func statsManifest() *BinaryManifest {
+	dataList := &FChunkDataList{ChunkLookup: map[uuid.UUID]uint32{}}
+	for _, chunk := range []*Chunk{
+		{Group: 1, WindowSize: 1000, FileSize: 400},
+		{Group: 1, WindowSize: 1000, FileSize: 600},
+		{Group: 2, WindowSize: 500, FileSize: 500},
+	} {
+		chunk.GUID = uuid.New()
+		dataList.ChunkLookup[chunk.GUID] = uint32(len(dataList.Chunks))
+		dataList.Chunks = append(dataList.Chunks, chunk)
+	}
+	c0, c1 := dataList.Chunks[0], dataList.Chunks[1]
+	part := func(c *Chunk, offset uint32, size uint32) ChunkPart {
+		return ChunkPart{ParentGUID: c.GUID, Chunk: c, Offset: offset, Size: size}
+	}
+
+	files := []File{
+		{FileName: "a", ChunkParts: []ChunkPart{part(c0, 0, 500), part(c0, 500, 100), part(c1, 0, 200)}},
+		{FileName: "b", InstallTags: []string{"hd"}, ChunkParts: []ChunkPart{part(c0, 600, 400)}},
+		{FileName: "c", InstallTags: []string{"hd", "fr"}, ChunkParts: []ChunkPart{part(c1, 200, 300)}},
+		{FileName: "link", SymlinkTarget: "a"},
+	}
+	return &BinaryManifest{
+		ChunkDataList:    dataList,
+		FileManifestList: &FFileManifestList{FileManifestList: files},
+	}
+}
+
+func TestComputeStats(t *testing.T) {
+	manifest := statsManifest()
+	stats := manifest.Stats(2)
+
+	chunks := manifest.ChunkDataList.Chunks
+	want := &ManifestStats{
+		FileCount:        4,
+		ChunkCount:       3,
+		ChunkPartCount:   5,
+		InstallSize:      1500,
+		DownloadSize:     1500,
+		WindowSize:       2500,
+		CompressionRatio: 0.6,
+		// a uses two parts of the first chunk but counts once
+		ChunkFileRefs:      map[uuid.UUID]int{chunks[0].GUID: 2, chunks[1].GUID: 2},
+		ChunkReuse:         2,
+		UnreferencedChunks: 1,
+		LargestFiles:       []FileSizeEntry{{"a", 800}, {"b", 400}},
+		Groups: map[uint8]GroupStats{
+			1: {ChunkCount: 2, DownloadSize: 1000, WindowSize: 2000},
+			2: {ChunkCount: 1, DownloadSize: 500, WindowSize: 500},
+		},
+	}
+	if !reflect.DeepEqual(stats, want) {
+		t.Errorf("Stats = %+v, want %+v", stats, want)
+	}
+
+	if files := manifest.Stats(-1).LargestFiles; len(files) != 4 {
+		t.Errorf("Stats(-1) listed %d files, want all 4", len(files))
+	}
+}
+
+func TestTagSizes(t *testing.T) {
+	sizes := statsManifest().FileManifestList.TagSizes()
+	want := map[string]InstallSize{
+		"":   {FileCount: 2, ChunkCount: 2, InstallSize: 800, DownloadSize: 1000},
+		"hd": {FileCount: 2, ChunkCount: 2, InstallSize: 700, DownloadSize: 1000},
+		"fr": {FileCount: 1, ChunkCount: 1, InstallSize: 300, DownloadSize: 600},
+	}
+	if !reflect.DeepEqual(sizes, want) {
+		t.Errorf("TagSizes = %+v, want %+v", sizes, want)
+	}
+}
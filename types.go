@@ -71,6 +71,15 @@ func (e EFeatureLevel) ChunkSubDir() string {
 	return "ChunksV4"
 }
 
+// FileDataSubDir returns the file data version sub directory, the counterpart of ChunkSubDir for file data (nochunks) builds
+func (e EFeatureLevel) FileDataSubDir() string {
+	if e < EFeatureLevelDataFileRenames {
+		return "Files"
+	}
+
+	return "FilesV2"
+}
+
 const (
 	StoredCompressed uint8 = 0x01
 	StoredEncrypted  uint8 = 0x02
@@ -0,0 +1,227 @@
+package egmanifest
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+)
+
+// UpdateSuffix is appended to the names of the new versions of files while an update stages them.
+const UpdateSuffix = ".egupdate"
+
+// oldRange is a range of a chunk that is present in a file of the installed build.
+type oldRange struct {
+	path        string
+	fileOffset  int64
+	chunkOffset uint32
+	size        uint32
+}
+
+// findOldRange returns an installed file range holding part, if there is one.
+func findOldRange(ranges map[uuid.UUID][]oldRange, part *ChunkPart) (oldRange, bool) {
+	for _, r := range ranges[part.ParentGUID] {
+		if r.chunkOffset <= part.Offset && uint64(part.Offset)+uint64(part.Size) <= uint64(r.chunkOffset)+uint64(r.size) {
+			r.fileOffset += int64(part.Offset - r.chunkOffset)
+			r.chunkOffset = part.Offset
+			r.size = part.Size
+			return r, true
+		}
+	}
+	return oldRange{}, false
+}
+
+// copyRange copies an old file range into a staged file.
+func copyRange(r oldRange, dst string, dstOffset int64) error {
+	src, err := os.Open(r.path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	buf := make([]byte, r.size)
+	_, err = src.ReadAt(buf, r.fileOffset)
+	if err != nil {
+		return err
+	}
+
+	fh, err := os.OpenFile(dst, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	_, err = fh.WriteAt(buf, dstOffset)
+	if closeErr := fh.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func sameFile(a *File, b *File) bool {
+	return a.SHAHash == b.SHAHash && a.SymlinkTarget == b.SymlinkTarget && a.Size() == b.Size()
+}
+
+// Update updates the build installed in dir from oldManifest to newManifest.
+// Only the chunks that can't be copied from the installed files are downloaded. Changed files are
+// staged next to the old ones and renamed over them once they're all built and verified, then the
+// files that aren't in newManifest anymore are deleted.
+func Update(ctx context.Context, oldManifest *BinaryManifest, newManifest *BinaryManifest, dir string, src ChunkSource) error {
+	// symlinks of the old build are on disk until the new one replaces them,
+	// nothing may be written or deleted below the ones of either build
+	oldList := oldManifest.FileManifestList.Files()
+	newList := newManifest.FileManifestList.Files()
+	oldPaths, err := installPaths(dir, oldList, oldList, newList)
+	if err != nil {
+		return err
+	}
+	newPaths, err := installPaths(dir, newList, oldList, newList)
+	if err != nil {
+		return err
+	}
+
+	oldFiles := map[string]*File{}
+	ranges := map[uuid.UUID][]oldRange{}
+	for _, file := range oldList {
+		oldFiles[file.FileName] = file
+		if file.SymlinkTarget != "" {
+			continue
+		}
+
+		path := oldPaths[file]
+		var offset int64
+		for _, part := range file.ChunkParts {
+			ranges[part.ParentGUID] = append(ranges[part.ParentGUID], oldRange{
+				path:        path,
+				fileOffset:  offset,
+				chunkOffset: part.Offset,
+				size:        part.Size,
+			})
+			offset += int64(part.Size)
+		}
+	}
+
+	stagedPath := func(file *File) string {
+		return newPaths[file] + UpdateSuffix
+	}
+
+	var changed, flagsChanged []*File
+	newNames := map[string]struct{}{}
+	for _, file := range newList {
+		newNames[file.FileName] = struct{}{}
+
+		old, ok := oldFiles[file.FileName]
+		if ok && sameFile(old, file) {
+			if old.FileMetaFlags != file.FileMetaFlags {
+				flagsChanged = append(flagsChanged, file)
+			}
+			continue
+		}
+		changed = append(changed, file)
+	}
+
+	// stage the changed files, copying what we can from the installed build
+	// and collecting the parts that have to be downloaded
+	cr := newChunkRefs()
+	for _, file := range changed {
+		path := stagedPath(file)
+		err := prepareFile(path, file)
+		if err != nil {
+			return err
+		}
+		if file.SymlinkTarget != "" {
+			continue
+		}
+
+		var offset int64
+		for idx := range file.ChunkParts {
+			part := &file.ChunkParts[idx]
+			r, ok := findOldRange(ranges, part)
+			if !ok || copyRange(r, path, offset) != nil {
+				cr.add(partRef{file: file, part: part, path: path, fileOffset: offset})
+			}
+			offset += int64(part.Size)
+		}
+	}
+
+	err = fetchChunkData(ctx, src, cr.order, func(chunk *Chunk, data []byte) error {
+		return writeChunkParts(data, cr.refs[chunk.GUID])
+	})
+	if err != nil {
+		return err
+	}
+
+	// an installed file we copied from could have been corrupt, rebuild those from scratch
+	var broken []*File
+	for _, file := range changed {
+		err = finishFile(stagedPath(file), file)
+		if err != nil {
+			return err
+		}
+		status, err := verifyPath(stagedPath(file), file)
+		if err != nil {
+			return err
+		}
+		if status != FileOK {
+			broken = append(broken, file)
+		}
+	}
+	if len(broken) != 0 {
+		err = assembleFilesAt(ctx, broken, src, stagedPath)
+		if err != nil {
+			return err
+		}
+		for _, file := range broken {
+			status, err := verifyPath(stagedPath(file), file)
+			if err != nil {
+				return err
+			}
+			if status != FileOK {
+				return fmt.Errorf("%s does not match the manifest after being rebuilt", file.FileName)
+			}
+		}
+	}
+
+	for _, file := range changed {
+		err = os.Rename(stagedPath(file), newPaths[file])
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, file := range flagsChanged {
+		err = finishFile(newPaths[file], file)
+		if err != nil {
+			return err
+		}
+	}
+
+	for name, file := range oldFiles {
+		if _, ok := newNames[name]; ok {
+			continue
+		}
+		path := oldPaths[file]
+		err = os.Remove(path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		removeEmptyDirs(dir, filepath.Dir(path))
+	}
+
+	return nil
+}
+
+// removeEmptyDirs removes path and its parents up to root as long as they're empty.
+func removeEmptyDirs(root string, path string) {
+	root = filepath.Clean(root)
+	for path = filepath.Clean(path); path != root && len(path) > len(root); path = filepath.Dir(path) {
+		entries, err := ioutil.ReadDir(path)
+		if err != nil || len(entries) != 0 {
+			return
+		}
+		if os.Remove(path) != nil {
+			return
+		}
+	}
+}
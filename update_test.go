@@ -0,0 +1,155 @@
+package egmanifest
+
+import (
+	"context"
+	"crypto/sha1"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// updatedManifest returns a manifest of the same build as m with files instead of its files.
+func updatedManifest(m *BinaryManifest, files []File) *BinaryManifest {
+	updated := *m
+	updated.FileManifestList = &FFileManifestList{Count: uint32(len(files)), FileManifestList: files}
+	return &updated
+}
+
+// partsFile builds a file made of parts of the chunks in src.
+func partsFile(t *testing.T, src memSource, name string, parts ...ChunkPart) File {
+	t.Helper()
+	var content []byte
+	for idx := range parts {
+		part := &parts[idx]
+		part.ParentGUID = part.Chunk.GUID
+		data, err := DecodeChunk(src[part.ParentGUID], part.Chunk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content = append(content, data[part.Offset:part.Offset+part.Size]...)
+	}
+	return File{FileName: name, SHAHash: sha1.Sum(content), ChunkParts: parts}
+}
+
+func checkUpdated(t *testing.T, manifest *BinaryManifest, dir string) {
+	t.Helper()
+	report, err := VerifyInstall(manifest, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() || len(report.Extra) != 0 {
+		t.Errorf("update doesn't verify: %v, extra %v", report.Results, report.Extra)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	oldManifest, src := testManifest()
+	oldFiles := oldManifest.FileManifestList.FileManifestList
+	oldFiles[0].FileMetaFlags = EFileMetaFlagsNone
+	dir := t.TempDir()
+	err := Install(context.Background(), oldManifest, dir, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exePath := filepath.Join(dir, "bin", "game.exe")
+	exeBefore, err := os.Stat(exePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c0, c1 := oldManifest.ChunkDataList.Chunks[0], oldManifest.ChunkDataList.Chunks[1]
+	exe := oldFiles[0]
+	// only the flags change
+	exe.FileMetaFlags = EFileMetaFlagsUnixExecutable
+	files := []File{
+		exe,
+		// changed, the range is in bin/game.exe
+		partsFile(t, src, "data/a.pak", ChunkPart{Chunk: c0, Offset: 100, Size: 400}),
+		// new, the range is in data/b.pak which is removed
+		partsFile(t, src, "data/c.pak", ChunkPart{Chunk: c1, Offset: 600, Size: 300}),
+		{FileName: "link", SymlinkTarget: "data/a.pak"},
+	}
+	newManifest := updatedManifest(oldManifest, files)
+
+	counter := &countingSource{memSource: src}
+	err = Update(context.Background(), oldManifest, newManifest, dir, counter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counter.fetched != 0 {
+		t.Errorf("fetched %d chunks, want everything copied from the old build", counter.fetched)
+	}
+	checkUpdated(t, newManifest, dir)
+
+	if _, err := os.Lstat(filepath.Join(dir, "data", "b.pak")); !os.IsNotExist(err) {
+		t.Errorf("removed file is still installed: %v", err)
+	}
+	target, err := os.Readlink(filepath.Join(dir, "link"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target != "data/a.pak" {
+		t.Errorf("link points to %q, want data/a.pak", target)
+	}
+
+	exeAfter, err := os.Stat(exePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(exeBefore, exeAfter) {
+		t.Errorf("a file whose flags changed was rewritten")
+	}
+	if runtime.GOOS != "windows" && exeAfter.Mode()&0111 == 0 {
+		t.Errorf("bin/game.exe mode %s, want executable", exeAfter.Mode())
+	}
+}
+
+func TestUpdateCorruptOldFile(t *testing.T) {
+	oldManifest, src := testManifest()
+	dir := t.TempDir()
+	err := Install(context.Background(), oldManifest, dir, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the range data/c.pak is copied from is corrupt
+	err = ioutil.WriteFile(filepath.Join(dir, "data", "b.pak"), make([]byte, 500), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c1 := oldManifest.ChunkDataList.Chunks[1]
+	files := append([]File(nil), oldManifest.FileManifestList.FileManifestList...)
+	files = append(files, partsFile(t, src, "data/c.pak", ChunkPart{Chunk: c1, Offset: 600, Size: 300}))
+	newManifest := updatedManifest(oldManifest, files)
+
+	counter := &countingSource{memSource: src}
+	err = Update(context.Background(), oldManifest, newManifest, dir, counter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counter.fetched != 1 {
+		t.Errorf("fetched %d chunks, want the one of the rebuilt file", counter.fetched)
+	}
+
+	report, err := VerifyInstall(newManifest, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, result := range report.Results {
+		want := FileOK
+		if result.File.FileName == "data/b.pak" {
+			// unchanged files are left alone
+			want = FileHashMismatch
+		}
+		if result.Status != want {
+			t.Errorf("%s: status %s, want %s", result.File.FileName, result.Status, want)
+		}
+	}
+	if len(report.Extra) != 0 {
+		t.Errorf("staged files were left behind: %v", report.Extra)
+	}
+}
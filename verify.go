@@ -0,0 +1,243 @@
+package egmanifest
+
+import (
+	"context"
+	"crypto/sha1"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+type FileStatus int
+
+const (
+	FileOK FileStatus = iota
+	FileMissing
+	FileSizeMismatch
+	FileHashMismatch
+	FileSymlinkMismatch
+	FileFlagsMismatch
+)
+
+func (s FileStatus) String() string {
+	switch s {
+	case FileOK:
+		return "ok"
+	case FileMissing:
+		return "missing"
+	case FileSizeMismatch:
+		return "size mismatch"
+	case FileHashMismatch:
+		return "hash mismatch"
+	case FileSymlinkMismatch:
+		return "symlink mismatch"
+	case FileFlagsMismatch:
+		return "flags mismatch"
+	}
+	return "unknown"
+}
+
+type VerifyResult struct {
+	File   *File
+	Status FileStatus
+}
+
+type VerifyReport struct {
+	// one result per file in the manifest, in manifest order
+	Results []VerifyResult
+	// slash separated paths of files in the directory that aren't in the manifest
+	Extra []string
+}
+
+// Missing returns the files that don't exist in the install directory.
+func (r *VerifyReport) Missing() []*File {
+	var out []*File
+	for _, result := range r.Results {
+		if result.Status == FileMissing {
+			out = append(out, result.File)
+		}
+	}
+	return out
+}
+
+// Corrupt returns the files that exist but don't match the manifest.
+func (r *VerifyReport) Corrupt() []*File {
+	var out []*File
+	for _, result := range r.Results {
+		if result.Status != FileOK && result.Status != FileMissing {
+			out = append(out, result.File)
+		}
+	}
+	return out
+}
+
+// Broken returns every file that needs to be repaired.
+func (r *VerifyReport) Broken() []*File {
+	var out []*File
+	for _, result := range r.Results {
+		if result.Status != FileOK {
+			out = append(out, result.File)
+		}
+	}
+	return out
+}
+
+// OK reports whether the install matches the manifest, extra files aside.
+func (r *VerifyReport) OK() bool {
+	for _, result := range r.Results {
+		if result.Status != FileOK {
+			return false
+		}
+	}
+	return true
+}
+
+// VerifyFile checks a single installed file against the manifest.
+func VerifyFile(dir string, file *File) (FileStatus, error) {
+	path, err := installPath(dir, file.FileName)
+	if err != nil {
+		return FileOK, err
+	}
+	return verifyPath(path, file)
+}
+
+func verifyPath(path string, file *File) (FileStatus, error) {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return FileMissing, nil
+	} else if err != nil {
+		return FileOK, err
+	}
+
+	if file.SymlinkTarget != "" {
+		if info.Mode()&os.ModeSymlink == 0 {
+			return FileSymlinkMismatch, nil
+		}
+		target, err := os.Readlink(path)
+		if err != nil {
+			return FileOK, err
+		}
+		if target != file.SymlinkTarget {
+			return FileSymlinkMismatch, nil
+		}
+		return FileOK, nil
+	}
+
+	if !info.Mode().IsRegular() {
+		return FileMissing, nil
+	}
+	if uint64(info.Size()) != file.Size() {
+		return FileSizeMismatch, nil
+	}
+
+	fh, err := os.Open(path)
+	if err != nil {
+		return FileOK, err
+	}
+	defer fh.Close()
+
+	hasher := sha1.New()
+	_, err = io.Copy(hasher, fh)
+	if err != nil {
+		return FileOK, err
+	}
+
+	var hash [20]byte
+	copy(hash[:], hasher.Sum(nil))
+	if hash != file.SHAHash {
+		return FileHashMismatch, nil
+	}
+
+	if !flagsMatch(info.Mode(), file) {
+		return FileFlagsMismatch, nil
+	}
+	return FileOK, nil
+}
+
+func flagsMatch(mode os.FileMode, file *File) bool {
+	if file.FileMetaFlags.IsReadOnly() && mode&0222 != 0 {
+		return false
+	}
+	// windows has no executable bit
+	if runtime.GOOS != "windows" && file.FileMetaFlags.IsExecutable() && mode&0111 == 0 {
+		return false
+	}
+	return true
+}
+
+// VerifyInstall walks an installed build in dir and checks it against manifest,
+// like the launcher's "Verify" button.
+func VerifyInstall(manifest *BinaryManifest, dir string) (*VerifyReport, error) {
+	files := manifest.FileManifestList.FileManifestList
+	report := &VerifyReport{Results: make([]VerifyResult, len(files))}
+	known := make(map[string]struct{}, len(files))
+
+	for idx := range files {
+		file := &files[idx]
+		known[file.FileName] = struct{}{}
+
+		status, err := VerifyFile(dir, file)
+		if err != nil {
+			return nil, err
+		}
+		report.Results[idx] = VerifyResult{File: file, Status: status}
+	}
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == JournalFileName {
+			return nil
+		}
+		if _, ok := known[rel]; !ok {
+			report.Extra = append(report.Extra, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// RepairInstall rebuilds the broken files found by VerifyInstall, fetching only the chunks they need.
+// Extra files are left alone.
+func RepairInstall(ctx context.Context, dir string, report *VerifyReport, src ChunkSource) error {
+	files := make([]*File, len(report.Results))
+	for idx, result := range report.Results {
+		files[idx] = result.File
+	}
+	paths, err := installPaths(dir, files, files)
+	if err != nil {
+		return err
+	}
+
+	var broken []*File
+	for _, result := range report.Results {
+		switch result.Status {
+		case FileOK:
+		case FileFlagsMismatch:
+			// the data is fine, only the permissions need fixing
+			err := finishFile(paths[result.File], result.File)
+			if err != nil {
+				return err
+			}
+		default:
+			broken = append(broken, result.File)
+		}
+	}
+	return assembleFiles(ctx, paths, broken, src)
+}
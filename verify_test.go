@@ -0,0 +1,94 @@
+package egmanifest
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestVerifyAndRepair(t *testing.T) {
+	manifest, src := testManifest()
+	dir := t.TempDir()
+
+	report, err := VerifyInstall(manifest, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if missing := report.Missing(); len(missing) != len(manifest.FileManifestList.FileManifestList) {
+		t.Errorf("empty dir: %d files missing, want all", len(missing))
+	}
+
+	err = RepairInstall(context.Background(), dir, report, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err = VerifyInstall(manifest, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() {
+		t.Fatalf("repaired install doesn't verify: %v", report.Results)
+	}
+
+	// corrupt a file, add an extra one and drop the executable bit
+	err = ioutil.WriteFile(filepath.Join(dir, "data", "a.pak"), make([]byte, 400), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "extra.txt"), nil, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Chmod(filepath.Join(dir, "bin", "game.exe"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err = VerifyInstall(manifest, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantStatus := map[string]FileStatus{"data/a.pak": FileHashMismatch}
+	if runtime.GOOS != "windows" {
+		wantStatus["bin/game.exe"] = FileFlagsMismatch
+	}
+	for _, result := range report.Results {
+		if want := wantStatus[result.File.FileName]; result.Status != want {
+			t.Errorf("%s: status %s, want %s", result.File.FileName, result.Status, want)
+		}
+	}
+	if len(report.Extra) != 1 || report.Extra[0] != "extra.txt" {
+		t.Errorf("extra files = %v, want [extra.txt]", report.Extra)
+	}
+
+	// only the corrupt file needs a chunk
+	counter := &countingSource{memSource: src}
+	err = RepairInstall(context.Background(), dir, report, counter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counter.fetched != 1 {
+		t.Errorf("repair fetched %d chunks, want 1", counter.fetched)
+	}
+
+	report, err = VerifyInstall(manifest, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() {
+		t.Errorf("repaired install doesn't verify: %v", report.Results)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "extra.txt")); err != nil {
+		t.Errorf("repair removed an extra file: %v", err)
+	}
+}
+
+func TestVerifyFileUnsafePath(t *testing.T) {
+	_, err := VerifyFile(t.TempDir(), &File{FileName: "../outside"})
+	if err == nil {
+		t.Errorf("VerifyFile accepted a path outside of the install dir")
+	}
+}